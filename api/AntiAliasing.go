@@ -0,0 +1,214 @@
+package api
+
+import (
+	"image/color"
+	"time"
+)
+
+// AntiAliasing selects how the engine smooths the edges of triangles. All modes
+// render at a multiple of the target resolution and average the samples of every
+// pixel before the frame is handed to the hooks. Modes are named after the scale
+// of the sample grid per axis, the samples per pixel are its square
+type AntiAliasing int
+
+const (
+	// AntiAliasingNone renders one sample per pixel
+	AntiAliasingNone AntiAliasing = iota
+
+	// AntiAliasingSSAAScale2 renders at twice the width and height of the target,
+	// so every pixel is the average of 2x2 samples. All 4 samples are shaded,
+	// which costs about 4 times the fill rate and memory of the target
+	AntiAliasingSSAAScale2
+
+	// AntiAliasingSSAAScale4 renders at four times the width and height of the
+	// target, 4x4 samples per pixel. All 16 samples are shaded, about 16 times
+	// the fill rate and memory of the target
+	AntiAliasingSSAAScale4
+
+	// AntiAliasingMSAAScale2 keeps coverage and depth of 2x2 samples per pixel
+	// like `AntiAliasingSSAAScale2`, but shades every triangle once per pixel.
+	// The color is shared by all samples of the pixel the triangle covers, the
+	// depth buffer still holds 4 samples per pixel
+	AntiAliasingMSAAScale2
+
+	// AntiAliasingMSAAScale4 keeps coverage and depth of 4x4 samples per pixel,
+	// 16 depth samples, and shades once per pixel
+	AntiAliasingMSAAScale4
+)
+
+// scale returns the number of samples per pixel in each direction
+func (a AntiAliasing) scale() int {
+	switch a {
+	case AntiAliasingSSAAScale2, AntiAliasingMSAAScale2:
+		return 2
+	case AntiAliasingSSAAScale4, AntiAliasingMSAAScale4:
+		return 4
+	}
+	return 1
+}
+
+// multisample returns true if triangles are shaded once per pixel
+func (a AntiAliasing) multisample() bool {
+	return a == AntiAliasingMSAAScale2 || a == AntiAliasingMSAAScale4
+}
+
+// sampleBuffer collects the samples of a frame rendered with anti-aliasing. The
+// engine renders into it at the sample resolution and resolves the samples into
+// the pixels of the target at the end of the frame
+type sampleBuffer struct {
+	scale       int
+	multisample bool
+
+	// Size of the target in pixels
+	w, h int
+
+	// Samples row by row, replacing the hooks while rendering
+	colors []color.RGBA
+	hook   SpanHook
+
+	// One resolved row of the target
+	resolved []color.RGBA
+
+	// Shading cache for multisampling: the triangle that last shaded a pixel
+	// and its color. Triangles are numbered throughout the lifetime of the
+	// engine, so the cache does not need to be cleared between frames
+	triangle uint32
+	shadedBy []uint32
+	shaded   []color.RGBA
+}
+
+// newSampleBuffer creates the sample buffer for a target of the given size
+func newSampleBuffer(mode AntiAliasing, w, h int) *sampleBuffer {
+	scale := mode.scale()
+	s := &sampleBuffer{
+		scale:       scale,
+		multisample: mode.multisample(),
+		w:           w,
+		h:           h,
+		colors:      make([]color.RGBA, w*scale*h*scale),
+		resolved:    make([]color.RGBA, w),
+	}
+	s.hook = s.write
+	if s.multisample {
+		s.shadedBy = make([]uint32, w*h)
+		s.shaded = make([]color.RGBA, w*h)
+	}
+	return s
+}
+
+// write stores a span of samples
+func (s *sampleBuffer) write(y, x0, x1 int, colors []color.RGBA, userData UserData) {
+	row := y * s.w * s.scale
+	copy(s.colors[row+x0:row+x1], colors)
+}
+
+// nextTriangle starts the shading cache for a new triangle
+func (s *sampleBuffer) nextTriangle() {
+	s.triangle++
+	if s.triangle == 0 {
+		clear(s.shadedBy)
+		s.triangle = 1
+	}
+}
+
+// pixel returns the index of the target pixel a sample of the current view
+// belongs to
+func (e *Engine) pixel(x, y int) int {
+	s := e.samples
+	return ((e.offsetY+y)/s.scale)*s.w + (e.offsetX+x)/s.scale
+}
+
+// cachedShade returns the color of the current triangle in the pixel of a sample
+// if the triangle was already shaded there
+func (e *Engine) cachedShade(x, y int) (color.RGBA, bool) {
+	s := e.samples
+	index := e.pixel(x, y)
+	if s.shadedBy[index] != s.triangle {
+		return color.RGBA{}, false
+	}
+	return s.shaded[index], true
+}
+
+// cacheShade stores the color of the current triangle in the pixel of a sample
+func (e *Engine) cacheShade(x, y int, c color.RGBA) {
+	s := e.samples
+	index := e.pixel(x, y)
+	s.shadedBy[index] = s.triangle
+	s.shaded[index] = c
+}
+
+// sampleScale returns the number of samples per pixel in each direction
+func (e *Engine) sampleScale() int {
+	if e.samples == nil {
+		return 1
+	}
+	return e.samples.scale
+}
+
+// targetSize returns the size of the target in pixels
+func (e *Engine) targetSize() (int, int) {
+	if e.samples == nil {
+		return e.w, e.h
+	}
+	return e.samples.w, e.samples.h
+}
+
+// resolveSamples averages the samples of every pixel and hands the pixels to the
+// hooks. Pixels without any sample drawn are skipped, pixels that are only
+// partly covered keep the share of their coverage in alpha
+func (e *Engine) resolveSamples(userData UserData) {
+	start := time.Now()
+	s := e.samples
+	stride := s.w * s.scale
+	count := uint32(s.scale * s.scale)
+
+	for y := 0; y < s.h; y++ {
+		covered := 0
+		for x := 0; x < s.w; x++ {
+			var r, g, b, a uint32
+			for sy := y * s.scale; sy < (y+1)*s.scale; sy++ {
+				for _, c := range s.colors[sy*stride+x*s.scale : sy*stride+(x+1)*s.scale] {
+					r += uint32(c.R)
+					g += uint32(c.G)
+					b += uint32(c.B)
+					a += uint32(c.A)
+				}
+			}
+			if a == 0 {
+				s.resolved[x] = color.RGBA{}
+				continue
+			}
+			s.resolved[x] = color.RGBA{
+				R: uint8((r + count/2) / count),
+				G: uint8((g + count/2) / count),
+				B: uint8((b + count/2) / count),
+				A: uint8((a + count/2) / count),
+			}
+			covered++
+		}
+		if covered == 0 {
+			continue
+		}
+
+		// Hand out runs of covered pixels
+		for x0 := 0; x0 < s.w; {
+			if s.resolved[x0].A == 0 {
+				x0++
+				continue
+			}
+			x1 := x0 + 1
+			for x1 < s.w && s.resolved[x1].A != 0 {
+				x1++
+			}
+			if e.drawSpan != nil {
+				e.drawSpan(y, x0, x1, s.resolved[x0:x1], userData)
+			} else {
+				for x := x0; x < x1; x++ {
+					e.drawPixel(x, y, s.resolved[x], userData)
+				}
+			}
+			x0 = x1
+		}
+	}
+	e.Metrics.FlushTime += time.Since(start)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"image/color"
+	"testing"
+)
+
+// renderAntiAliased renders a cube with a fragment shader and returns the pixels
+// drawn and the number of fragments shaded
+func renderAntiAliased(t *testing.T, antiAliasing AntiAliasing) (map[[2]int]color.RGBA, int) {
+	drawn := map[[2]int]color.RGBA{}
+	engine := NewEngine(32, 32, 90, func(x, y int, c color.Color, userData UserData) {
+		if x < 0 || y < 0 || x >= 32 || y >= 32 {
+			t.Fatalf("pixel %d/%d outside of the target", x, y)
+		}
+		// Resolved frames hand out every pixel once
+		if _, ok := drawn[[2]int{x, y}]; ok && antiAliasing != AntiAliasingNone {
+			t.Fatalf("pixel %d/%d drawn twice", x, y)
+		}
+		drawn[[2]int{x, y}] = color.RGBAModel.Convert(c).(color.RGBA)
+	}, &EngineOptions{AntiAliasing: antiAliasing})
+
+	fragments := 0
+	mesh := ColoredCube()
+	mesh.SetMaterial(&Material{
+		FragmentShader: func(in *FragmentInput) (color.Color, bool) {
+			fragments++
+			return color.RGBA{R: 255, A: 255}, true
+		},
+	})
+	engine.AddMesh(mesh)
+	engine.SetCameraPositionAbsolute(0.5, 0.5, -1.5, 0.3, 0.2)
+	engine.Render(nil)
+	return drawn, fragments
+}
+
+func TestAntiAliasing(t *testing.T) {
+	aliased, aliasedFragments := renderAntiAliased(t, AntiAliasingNone)
+	for position, c := range aliased {
+		if c.A != 255 {
+			t.Fatalf("pixel %v: expected no partial coverage without anti-aliasing", position)
+		}
+	}
+
+	for _, antiAliasing := range []AntiAliasing{AntiAliasingSSAAScale2, AntiAliasingSSAAScale4, AntiAliasingMSAAScale2, AntiAliasingMSAAScale4} {
+		drawn, fragments := renderAntiAliased(t, antiAliasing)
+
+		partial := 0
+		for _, c := range drawn {
+			if c.A < 255 {
+				partial++
+				if c.R != c.A || c.G != 0 || c.B != 0 {
+					t.Fatalf("mode %d: expected the premultiplied color of the cube, got %v", antiAliasing, c)
+				}
+			}
+		}
+		if partial == 0 {
+			t.Fatalf("mode %d: expected partly covered pixels along the edges", antiAliasing)
+		}
+		if len(drawn) < len(aliased)*9/10 || len(drawn) > len(aliased)*11/10+partial {
+			t.Fatalf("mode %d: expected about %d pixels, got %d", antiAliasing, len(aliased), len(drawn))
+		}
+
+		// Supersampling shades every sample, multisampling about every pixel
+		samples := antiAliasing.scale() * antiAliasing.scale()
+		if antiAliasing.multisample() {
+			if fragments > 2*aliasedFragments {
+				t.Fatalf("mode %d: expected about %d fragments, got %d", antiAliasing, aliasedFragments, fragments)
+			}
+		} else if fragments < samples*aliasedFragments*9/10 {
+			t.Fatalf("mode %d: expected about %d fragments, got %d", antiAliasing, samples*aliasedFragments, fragments)
+		}
+	}
+}
+
+func TestAntiAliasing_Viewport(t *testing.T) {
+	drawn := 0
+	engine := NewEngine(64, 32, 90, nil, &EngineOptions{
+		AntiAliasing: AntiAliasingSSAAScale2,
+		SpanHook: func(y, x0, x1 int, colors []color.RGBA, userData UserData) {
+			if y < 0 || y >= 32 || x0 < 32 || x1 > 64 {
+				t.Fatalf("span %d..%d in row %d outside of the viewport", x0, x1, y)
+			}
+			drawn += x1 - x0
+		},
+	})
+	engine.AddMesh(ColoredCube())
+	engine.SetBackground(&SolidBackground{Color: color.Black})
+	viewport := engine.AddViewport(32, 0, 32, 32, 90)
+	viewport.SetCameraPositionAbsolute(0.5, 0.5, -1.5, 0, 0)
+	engine.Render(nil)
+
+	if x, y, w, h := viewport.Bounds(); x != 32 || y != 0 || w != 32 || h != 32 {
+		t.Fatalf("expected the bounds in pixels of the target, got %d/%d %dx%d", x, y, w, h)
+	}
+	if drawn != 32*32 {
+		t.Fatalf("expected the viewport to be covered, got %d pixels", drawn)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"image/color"
+	"math"
+)
+
+// Slots of the attribute vector. Attributes are divided by w after projection
+// so that they can be interpolated linearly in screen space and corrected per
+// pixel
+const (
+	// AttributeW is 1 for every vertex before projection. After the divide it
+	// holds 1/w, which is used for depth testing and to undo the divide
+	AttributeW = iota
+
+	// Texture coordinates
+	AttributeU
+	AttributeV
+
+	// Vertex colors, premultiplied with alpha and scaled to 0..1
+	AttributeR
+	AttributeG
+	AttributeB
+	AttributeA
+
+	// First of the `MaxVaryings` slots written by vertex shaders
+	AttributeVaryings
+
+	// World position, only filled in for triangles receiving shadows and for
+	// height fog
+	AttributeWorldX = AttributeVaryings + MaxVaryings
+	AttributeWorldY = AttributeWorldX + 1
+	AttributeWorldZ = AttributeWorldX + 2
+
+	// MaxAttributes is the size of the attribute vector
+	MaxAttributes = AttributeWorldZ + 1
+)
+
+// Attributes are the values of a vertex that are interpolated across a triangle
+type Attributes [MaxAttributes]float64
+
+// Lerp returns the attributes between `a` and `b` at `t`
+func (a *Attributes) Lerp(b *Attributes, t float64) Attributes {
+	result := Attributes{}
+	for i := range result {
+		result[i] = a[i] + t*(b[i]-a[i])
+	}
+	return result
+}
+
+// attributeColor converts the interpolated color attributes back into a color
+func attributeColor(attributes *Attributes, invW float64) color.RGBA64 {
+	channel := func(slot int) uint16 {
+		return uint16(math.Max(0, math.Min(1, attributes[slot]*invW)) * 0xFFFF)
+	}
+	return color.RGBA64{R: channel(AttributeR), G: channel(AttributeG), B: channel(AttributeB), A: channel(AttributeA)}
+}
+
+// lerpColor returns the color between `a` and `b` at `t`
+func lerpColor(a, b color.Color, t float64) color.Color {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	channel := func(x, y uint32) uint16 {
+		return uint16(float64(x) + t*(float64(y)-float64(x)))
+	}
+	return color.RGBA64{R: channel(ar, br), G: channel(ag, bg), B: channel(ab, bb), A: channel(aa, ba)}
+}
+
+// vertexAttributes returns the attributes of a triangle vertex before projection
+func vertexAttributes(triangle *Triangle, index int) Attributes {
+	result := Attributes{}
+	result[AttributeW] = 1
+	result[AttributeU] = triangle.UVs[index].U
+	result[AttributeV] = triangle.UVs[index].V
+	if triangle.hasVertexColors() {
+		r, g, b, a := triangle.vertexColor(index).RGBA()
+		result[AttributeR] = float64(r) / 0xFFFF
+		result[AttributeG] = float64(g) / 0xFFFF
+		result[AttributeB] = float64(b) / 0xFFFF
+		result[AttributeA] = float64(a) / 0xFFFF
+	}
+	return result
+}
+
+// setWorldAttributes stores the world position of a vertex, which is needed to
+// look up the shadow maps and for height fog
+func setWorldAttributes(attributes *Attributes, world *Vector3d) {
+	attributes[AttributeWorldX] = world.X
+	attributes[AttributeWorldY] = world.Y
+	attributes[AttributeWorldZ] = world.Z
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"image/color"
+	"math"
+	"time"
+)
+
+// Background fills the pixels not covered by any geometry. It is drawn from the
+// orientation of the camera, but not its position, so it appears infinitely far
+// away
+type Background interface {
+	// Sample returns the color seen in a normalized view direction in world space
+	Sample(direction Vector3d) color.RGBA
+}
+
+// SolidBackground clears the screen in a single color, which must be set
+type SolidBackground struct {
+	Color color.Color
+}
+
+func (b *SolidBackground) Sample(direction Vector3d) color.RGBA {
+	return toRGBA(b.Color)
+}
+
+// GradientBackground blends vertically from `Bottom` straight below the camera to
+// `Top` straight above. Both colors must be set
+type GradientBackground struct {
+	Top, Bottom color.Color
+}
+
+func (b *GradientBackground) Sample(direction Vector3d) color.RGBA {
+	t := (direction.Y + 1) / 2
+	br, bg, bb, ba := b.Bottom.RGBA()
+	tr, tg, tb, ta := b.Top.RGBA()
+	channel := func(x, y uint32) uint8 {
+		return uint8((float64(x) + t*(float64(y)-float64(x))) / 0x101)
+	}
+	return color.RGBA{R: channel(br, tr), G: channel(bg, tg), B: channel(bb, tb), A: channel(ba, ta)}
+}
+
+// EquirectangularBackground wraps a panorama around the camera. The horizontal
+// center of the texture lies in direction +Z, the top and bottom rows straight
+// above and below
+type EquirectangularBackground struct {
+	Texture TextureAtlas
+}
+
+func (b *EquirectangularBackground) Sample(direction Vector3d) color.RGBA {
+	u := 0.5 + math.Atan2(direction.X, direction.Z)/(2*math.Pi)
+	v := 0.5 - math.Asin(max(-1, min(1, direction.Y)))/math.Pi
+	return sampleFace(b.Texture, u, v)
+}
+
+// Faces of a cubemap
+const (
+	CubemapPositiveX = iota
+	CubemapNegativeX
+	CubemapPositiveY
+	CubemapNegativeY
+	CubemapPositiveZ
+	CubemapNegativeZ
+)
+
+// CubemapBackground surrounds the camera with the six faces of a cube, indexed by
+// `CubemapPositiveX` and the following constants. The faces are oriented like
+// OpenGL cubemaps
+type CubemapBackground struct {
+	Faces [6]TextureAtlas
+}
+
+func (b *CubemapBackground) Sample(direction Vector3d) color.RGBA {
+	x, y, z := direction.X, direction.Y, direction.Z
+	ax, ay, az := math.Abs(x), math.Abs(y), math.Abs(z)
+
+	// The axis with the largest component selects the face
+	var face int
+	var s, t, major float64
+	switch {
+	case ax >= ay && ax >= az:
+		major = ax
+		if x > 0 {
+			face, s, t = CubemapPositiveX, -z, -y
+		} else {
+			face, s, t = CubemapNegativeX, z, -y
+		}
+	case ay >= az:
+		major = ay
+		if y > 0 {
+			face, s, t = CubemapPositiveY, x, z
+		} else {
+			face, s, t = CubemapNegativeY, x, -z
+		}
+	default:
+		major = az
+		if z > 0 {
+			face, s, t = CubemapPositiveZ, x, -y
+		} else {
+			face, s, t = CubemapNegativeZ, -x, -y
+		}
+	}
+	return sampleFace(b.Faces[face], (s/major+1)/2, (t/major+1)/2)
+}
+
+// sampleFace returns the texel of a background texture at coordinates from 0 to 1
+// with the origin in the upper left corner. Missing textures are black
+func sampleFace(texture TextureAtlas, u, v float64) color.RGBA {
+	if texture == nil {
+		return color.RGBA{A: 255}
+	}
+	x := max(0, min(texture.W()-1, int(u*float64(texture.W()))))
+	y := max(0, min(texture.H()-1, int(v*float64(texture.H()))))
+	return toRGBA(texture.ColorAt(x, y))
+}
+
+// SetBackground sets the background drawn behind all geometry, nil leaves the
+// uncovered pixels untouched
+func (e *Engine) SetBackground(background Background) {
+	e.background = background
+}
+
+// Background returns the current background
+func (e *Engine) Background() Background {
+	return e.background
+}
+
+// renderBackground draws the background into all pixels that no geometry was
+// drawn to in this frame. The depth buffer is left untouched
+func (e *Engine) renderBackground(userData UserData) {
+	start := time.Now()
+
+	// View rays through the screen are interpolated linearly, only their
+	// direction matters
+	camera := e.view.Inverse()
+	ray := func(x, y float64) Vector3d {
+		// Undo the projection, X/Y are inverted on screen
+		direction := Vector3d{
+			X: (1 - 2*x/e.W) / e.projection[0][0],
+			Y: (1 - 2*y/e.H) / e.projection[1][1],
+			Z: 1,
+		}
+		return camera.MulV(&direction)
+	}
+
+	for y := 0; y < e.h; y++ {
+		first := ray(0.5, float64(y)+0.5)
+		next := ray(1.5, float64(y)+0.5)
+		step := next.Sub(&first)
+
+		for x := 0; x < e.w; x++ {
+			if e.depthBuffer.At(x, y) > 0 {
+				continue
+			}
+			direction := step.Mul(float64(x))
+			direction = first.Add(&direction)
+			direction.Normalize()
+			e.plotColor(x, y, e.background.Sample(direction), userData)
+		}
+		e.flushSpan(userData)
+	}
+
+	e.Metrics.RasterizationTime += time.Since(start)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// renderBackgroundScene renders a scene and returns the color of every pixel, the
+// number of pixels drawn and the number of pixels with a depth
+func renderBackgroundScene(t *testing.T, background Background, mesh *Mesh, yaw float64) ([][]color.RGBA, int, int) {
+	pixels := make([][]color.RGBA, 32)
+	for y := range pixels {
+		pixels[y] = make([]color.RGBA, 32)
+	}
+	drawn := map[[2]int]bool{}
+	engine := NewEngine(32, 32, 90, func(x, y int, c color.Color, userData UserData) {
+		if drawn[[2]int{x, y}] {
+			t.Fatalf("pixel %d/%d drawn twice", x, y)
+		}
+		drawn[[2]int{x, y}] = true
+		pixels[y][x] = color.RGBAModel.Convert(c).(color.RGBA)
+	}, nil)
+	if mesh != nil {
+		engine.AddMesh(mesh)
+	}
+	engine.SetBackground(background)
+	engine.SetCameraPositionAbsolute(0.5, 0.5, -2, yaw, 0)
+	engine.Render(nil)
+
+	covered := 0
+	for _, depth := range engine.depthBuffer.Entries {
+		if depth > 0 {
+			covered++
+		}
+	}
+	return pixels, len(drawn), covered
+}
+
+func TestBackground_Solid(t *testing.T) {
+	sky := color.RGBA{R: 10, G: 20, B: 30, A: 255}
+	pixels, drawn, covered := renderBackgroundScene(t, &SolidBackground{Color: sky}, ColoredCube(), 0)
+	if drawn != 32*32 {
+		t.Fatalf("expected every pixel to be drawn once, got %d", drawn)
+	}
+	if pixels[0][0] != sky || pixels[16][16] == sky {
+		t.Fatalf("expected the cube in front of the background")
+	}
+
+	// Only the cube has a depth
+	cube := 0
+	for _, row := range pixels {
+		for _, c := range row {
+			if c != sky {
+				cube++
+			}
+		}
+	}
+	if covered != cube {
+		t.Fatalf("expected %d pixels with depth, got %d", cube, covered)
+	}
+}
+
+func TestBackground_Gradient(t *testing.T) {
+	top := color.RGBA{B: 255, A: 255}
+	bottom := color.RGBA{G: 255, A: 255}
+	pixels, _, _ := renderBackgroundScene(t, &GradientBackground{Top: top, Bottom: bottom}, nil, 0)
+
+	// Looking at the horizon, the upper half leans to the top color
+	upper, lower := pixels[0][16], pixels[31][16]
+	center := pixels[16][16]
+	if upper.B <= upper.G || lower.G <= lower.B || math.Abs(float64(center.B)-float64(center.G)) > 10 {
+		t.Fatalf("unexpected gradient %v %v %v", upper, pixels[16][16], lower)
+	}
+}
+
+func TestBackground_Cubemap(t *testing.T) {
+	background := &CubemapBackground{}
+	for face := range background.Faces {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, color.RGBA{R: uint8(face), A: 255})
+		texture, err := NewImageTexture(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		background.Faces[face] = texture
+	}
+
+	for _, test := range []struct {
+		yaw  float64
+		face int
+	}{{0, CubemapPositiveZ}, {math.Pi, CubemapNegativeZ}} {
+		pixels, _, _ := renderBackgroundScene(t, background, nil, test.yaw)
+		if face := int(pixels[16][16].R); face != test.face {
+			t.Fatalf("yaw %v: expected face %d, got %d", test.yaw, test.face, face)
+		}
+	}
+}
+
+func TestBackground_Equirectangular(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	texture, err := NewImageTexture(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixels, _, _ := renderBackgroundScene(t, &EquirectangularBackground{Texture: texture}, nil, 0)
+
+	// Straight ahead is the center of the panorama, up is the upper half
+	if c := pixels[8][16]; c.R != 1 || c.G != 0 {
+		t.Fatalf("unexpected texel %v", c)
+	}
+	if c := pixels[24][16]; c.R != 1 || c.G != 1 {
+		t.Fatalf("unexpected texel %v", c)
+	}
+}
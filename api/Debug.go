@@ -0,0 +1,161 @@
+package api
+
+import (
+	"image/color"
+	"time"
+)
+
+// DebugMode replaces the normal output of the engine with a visualization that
+// helps to find expensive parts of a scene. The output goes through the same
+// hooks as normal rendering
+type DebugMode int
+
+const (
+	// DebugNone renders normally
+	DebugNone DebugMode = iota
+
+	// DebugOverdraw shows how often every pixel was written as a heatmap, from
+	// black (never) over blue, green and yellow to red (`DebugOverdrawMax` times
+	// or more)
+	DebugOverdraw
+
+	// DebugDepth shows the depth buffer in grayscale, brighter is closer. The
+	// range is stretched to the closest and farthest pixel of the frame
+	DebugDepth
+
+	// DebugTriangleIndex draws every triangle in a color derived from its index,
+	// so the tessellation of meshes becomes visible
+	DebugTriangleIndex
+
+	// DebugBackfaces renders normally, but also draws the triangles that backface
+	// culling would remove, in magenta
+	DebugBackfaces
+)
+
+// DebugOverdrawMax is the overdraw count shown in the hottest color
+const DebugOverdrawMax = 8
+
+// debugBackfaceColor highlights triangles facing away from the camera
+var debugBackfaceColor = color.RGBA{R: 255, G: 0, B: 255, A: 255}
+
+// SetDebugMode switches the debug visualization, `DebugNone` turns it off
+func (e *Engine) SetDebugMode(mode DebugMode) {
+	e.debugMode = mode
+	if mode == DebugOverdraw && e.debugCounts == nil {
+		e.debugCounts = make([]uint16, e.w*e.h)
+	}
+}
+
+// DebugMode returns the current debug visualization
+func (e *Engine) DebugMode() DebugMode {
+	return e.debugMode
+}
+
+// debugBuffered returns true if the debug mode collects values while rendering
+// and outputs them at the end of the frame
+func (e *Engine) debugBuffered() bool {
+	return e.debugMode == DebugOverdraw || e.debugMode == DebugDepth
+}
+
+// debugPixel records a pixel that passed the depth test in buffered debug modes
+func (e *Engine) debugPixel(x, y int) {
+	if e.debugMode == DebugOverdraw {
+		index := y*e.w + x
+		if e.debugCounts[index] < 0xFFFF {
+			e.debugCounts[index]++
+		}
+	}
+}
+
+// debugShade draws a pixel of a triangle in debug modes and returns false if the
+// normal shading should be used instead. The fragment stage is skipped for debug
+// output
+func (e *Engine) debugShade(r *rasterTriangle, x, y int) bool {
+	switch {
+	case e.debugBuffered():
+		e.debugPixel(x, y)
+	case e.debugMode == DebugTriangleIndex:
+		e.plotColor(x, y, debugTriangleColor(r.triangle.index), r.userData)
+	case e.debugMode == DebugBackfaces && r.triangle.backface:
+		e.plotColor(x, y, debugBackfaceColor, r.userData)
+	default:
+		return false
+	}
+	return true
+}
+
+// debugTriangleColor returns a color for a triangle index. Neighbouring indices
+// get very different colors
+func debugTriangleColor(index int) color.RGBA {
+	// Spread the bits of the index with a multiplicative hash
+	hash := uint32(index+1) * 2654435761
+	return color.RGBA{R: uint8(hash >> 24), G: uint8(hash >> 16), B: uint8(hash >> 8), A: 255}
+}
+
+// heatmapColor maps a value between 0 and 1 to black, blue, green, yellow and red
+func heatmapColor(t float64) color.RGBA {
+	t = max(0, min(1, t))
+	stops := [...]color.RGBA{
+		{A: 255},
+		{B: 255, A: 255},
+		{G: 255, A: 255},
+		{R: 255, G: 255, A: 255},
+		{R: 255, A: 255},
+	}
+	position := t * float64(len(stops)-1)
+	i := min(int(position), len(stops)-2)
+	f := position - float64(i)
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + f*(float64(b)-float64(a)))
+	}
+	return color.RGBA{
+		R: lerp(stops[i].R, stops[i+1].R),
+		G: lerp(stops[i].G, stops[i+1].G),
+		B: lerp(stops[i].B, stops[i+1].B),
+		A: 255,
+	}
+}
+
+// resolveDebug outputs the values collected by buffered debug modes for every
+// pixel of the screen
+func (e *Engine) resolveDebug(userData UserData) {
+	start := time.Now()
+
+	// Stretch the depth range of the frame to the whole gray range
+	nearest, farthest := 0.0, 0.0
+	if e.debugMode == DebugDepth {
+		for _, depth := range e.depthBuffer.Entries {
+			if depth <= 0 {
+				continue
+			}
+			if nearest == 0 || depth > nearest {
+				nearest = depth
+			}
+			if farthest == 0 || depth < farthest {
+				farthest = depth
+			}
+		}
+	}
+
+	for y := 0; y < e.h; y++ {
+		for x := 0; x < e.w; x++ {
+			c := color.RGBA{A: 255}
+			switch e.debugMode {
+			case DebugOverdraw:
+				c = heatmapColor(float64(e.debugCounts[y*e.w+x]) / DebugOverdrawMax)
+			case DebugDepth:
+				if depth := e.depthBuffer.At(x, y); depth > 0 {
+					gray := uint8(255)
+					if nearest > farthest {
+						gray = uint8(32 + 223*(depth-farthest)/(nearest-farthest))
+					}
+					c = color.RGBA{R: gray, G: gray, B: gray, A: 255}
+				}
+			}
+			e.plotColor(x, y, c, userData)
+		}
+		e.flushSpan(userData)
+	}
+
+	e.Metrics.FlushTime += time.Since(start)
+}
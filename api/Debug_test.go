@@ -0,0 +1,74 @@
+package api
+
+import (
+	"image/color"
+	"testing"
+)
+
+// renderDebug renders the benchmark scene in a debug mode and returns the colors
+// of all pixels written
+func renderDebug(t *testing.T, mode DebugMode) (map[[2]int]color.RGBA, *Engine) {
+	pixels := map[[2]int]color.RGBA{}
+	engine := benchmarkScene(func(x, y int, c color.Color, userData UserData) {
+		pixels[[2]int{x, y}] = color.RGBAModel.Convert(c).(color.RGBA)
+	}, nil)
+	engine.SetDebugMode(mode)
+	engine.Render(nil)
+	return pixels, engine
+}
+
+func TestEngine_DebugBufferedModes(t *testing.T) {
+	for _, mode := range []DebugMode{DebugOverdraw, DebugDepth} {
+		pixels, engine := renderDebug(t, mode)
+		if len(pixels) != engine.w*engine.h {
+			t.Fatalf("mode %d: expected every pixel to be written, got %d", mode, len(pixels))
+		}
+		if engine.Metrics.FlushTime <= 0 {
+			t.Fatalf("mode %d: expected the resolve pass to be timed", mode)
+		}
+
+		colors := map[color.RGBA]bool{}
+		for _, c := range pixels {
+			colors[c] = true
+		}
+		if len(colors) < 3 {
+			t.Fatalf("mode %d: expected a gradient, got %d colors", mode, len(colors))
+		}
+	}
+}
+
+func TestEngine_DebugTriangleIndex(t *testing.T) {
+	pixels, _ := renderDebug(t, DebugTriangleIndex)
+	colors := map[color.RGBA]bool{}
+	for _, c := range pixels {
+		colors[c] = true
+	}
+	if len(colors) < 10 {
+		t.Fatalf("expected a color per triangle, got %d colors", len(colors))
+	}
+}
+
+func TestEngine_DebugBackfaces(t *testing.T) {
+	// From inside a cube all faces point away from the camera. The edge function
+	// rasterizer covers the screen up to the last row and column
+	pixels := 0
+	engine := NewEngine(64, 64, 90, func(x, y int, c color.Color, userData UserData) {
+		if color.RGBAModel.Convert(c).(color.RGBA) != debugBackfaceColor {
+			t.Fatalf("expected backface color, got %v", c)
+		}
+		pixels++
+	}, &EngineOptions{Rasterizer: RasterizerEdgeFunction})
+	engine.AddMesh(ColoredCube())
+	engine.SetCameraPositionAbsolute(0.5, 0.5, 0.5, 0, 0)
+
+	engine.Render(nil)
+	if pixels != 0 {
+		t.Fatalf("expected all faces to be culled, got %d pixels", pixels)
+	}
+
+	engine.SetDebugMode(DebugBackfaces)
+	engine.Render(nil)
+	if pixels != 64*64 {
+		t.Fatalf("expected the whole screen to be highlighted, got %d pixels", pixels)
+	}
+}
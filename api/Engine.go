@@ -7,47 +7,106 @@ import (
 )
 
 type UserData interface{}
+
+// DrawHook receives a single pixel. Vertex colors, shadowed and fogged colors are
+// computed per pixel and allocated when passed as `color.Color`, use a `SpanHook`
+// for allocation free rendering
 type DrawHook func(x, y int, c color.Color, userData UserData)
+
+// SpanHook receives a horizontal run of pixels from x0 up to but excluding x1 in
+// row y. The colors slice is reused by the engine and only valid during the call.
+// Colors are passed by value, so unlike `DrawHook` interpolated colors do not
+// need to be allocated
+type SpanHook func(y, x0, x1 int, colors []color.RGBA, userData UserData)
 type Engine struct {
-	// Internal viewport dimensions
-	w, h int
-	W, H float64
+	// The rectangle, camera and depth buffer currently rendered. Covers the whole
+	// target, unless a viewport is rendered
+	viewState
 
 	// List of meshes to render
 	meshes []*Mesh
 
-	// Camera yaw angle in radians (left/right)
-	yaw float64
+	// List of terrains to render, their meshes depend on the camera position
+	terrains []*Terrain
+
+	// Lights casting shadows. While their shadow maps are rendered, only the
+	// depth of triangles is written
+	lights    []*Light
+	depthOnly bool
+
+	// Optional fog
+	fog *Fog
+
+	// Optional background filling the pixels not covered by geometry
+	background Background
 
-	// Camera pitch angle in radians (up/down)
-	pitch float64
+	// Number of calls to `Render`
+	frame uint64
 
-	// Camera position
-	camera Vector3d
+	// Optional viewports rendered instead of the camera of the engine
+	viewports []*Viewport
 
-	// View matrix
-	view Matrix4x4
+	// Post effects and the buffers they work on. While a frame is rendered for
+	// post processing, bufferSpan replaces the hooks
+	postEffects []PostEffect
+	frameBuffer Frame
+	postBuffer  []color.RGBA
+	bufferSpan  SpanHook
 
-	// Vector pointing to the current forward direction of the camera
-	direction Vector3d
+	// Optional samples of anti-aliasing, replacing the hooks while rendering
+	samples *sampleBuffer
 
-	// Projection matrix to project from 3D into 2D
-	projection Matrix4x4
+	// Optional retro features. While dithering, ditherHook replaces the hooks and
+	// hands the dithered pixels to ditherOutput
+	retro        *Retro
+	ditherHook   SpanHook
+	ditherOutput SpanHook
+	ditherBuffer []color.RGBA
+	painterQueue []paintedTriangle
+
+	// Optional palette output, its buffer replaces the hooks
+	palette *paletteBuffer
 
 	// Optional texture atlas
 	// If this is not set, triangles must have a defined color
 	textureAtlas TextureAtlas
 
-	// depthBuffer helps to avoid drawing pixels that have already been filled
-	// this implementation does not allow for transparent materials
-	depthBuffer *DepthBuffer
-
 	// Hooks - callback functions to be defined by the user of the library
 	drawPixel DrawHook
 
+	// Optional, replaces drawPixel if set. Pixels are collected in spanBuffer
+	// until the run ends
+	drawSpan   SpanHook
+	spanBuffer []color.RGBA
+	spanX      int
+	spanY      int
+
 	// yOrigin set the position of the (0/0) coordinate
 	yOrigin YOrigin
 
+	// Rasterization core and its sub pixel precision
+	rasterizer   Rasterizer
+	subPixelBits int
+
+	// Scratch buffers of the pipeline, reused for every mesh and frame
+	trianglesToRaster []Triangle
+	clipCurrent       []Triangle
+	clipNext          []Triangle
+	rasterQueue       []Triangle
+
+	uniforms    Uniforms
+	raster      rasterTriangle
+	vertexInput VertexInput
+	varyings    Varyings
+
+	// Index of the first triangle of the current mesh in the frame
+	triangleIndex int
+
+	// Debug visualization replacing the normal output and the per pixel values
+	// collected for it
+	debugMode   DebugMode
+	debugCounts []uint16
+
 	// Metrics contains performance indicators
 	Metrics Metrics
 }
@@ -57,482 +116,403 @@ func (e *Engine) AddMesh(mesh *Mesh) {
 	e.meshes = append(e.meshes, mesh)
 }
 
-// GetCameraPosition returns the current position of the camera
-func (e *Engine) GetCameraPosition() (x, y, z, yaw, pitch float64) {
-	return e.camera.X, e.camera.Y, e.camera.Z, e.yaw, e.pitch
-}
-
-// SetCameraPositionAbsolute sets the camera to the given absolute position
-func (e *Engine) SetCameraPositionAbsolute(x, y, z, yaw, pitch float64) {
-	e.camera.X = x
-	e.camera.Y = y
-	e.camera.Z = z
-	e.yaw = yaw
-	e.pitch = pitch
+// AddTerrain adds a terrain to the engine in order to be rendered
+func (e *Engine) AddTerrain(terrain *Terrain) {
+	e.terrains = append(e.terrains, terrain)
 }
 
-// SetCameraPositionRelative move the camera to a new position given the offsets
-func (e *Engine) SetCameraPositionRelative(dx, dy, dz, yaw, pitch float64) {
-	e.camera.X += dx
-	e.camera.Y += dy
-	e.camera.Z += dz
-	e.yaw += yaw
-	e.pitch += pitch
+// AddLight adds a shadow casting light to the engine
+func (e *Engine) AddLight(light *Light) {
+	e.lights = append(e.lights, light)
 }
 
-func (e *Engine) MoveCameraForward(amount float64) {
-	forward := e.direction.Mul(amount)
-	e.camera = e.camera.Add(&forward)
-}
-
-// updateCamera updates the global camera
-func (e *Engine) updateCamera() {
-	up := Vector3d{X: 0, Y: 1, Z: 0}
-	target := Vector3d{X: 0, Y: 0, Z: 1}
-
-	// Apply camera rotations
-	cameraYaw := Identity4x4()
-	cameraPitch := Identity4x4()
+// projectToScreen performs the perspective divide on a triangle in clip space and
+// maps it to screen coordinates
+func (e *Engine) projectToScreen(triangle *Triangle) {
+	if e.retro.affine() {
+		// Only the depth is divided, the other attributes are interpolated as
+		// they are
+		for i := range triangle.Attributes {
+			triangle.Attributes[i][AttributeW] = 1 / triangle.Vertices[i].W
+		}
+	} else {
+		triangle.ScaleAttributes()
+	}
+	triangle.ScaleW()
 
-	// TODO figure out why camera roll is not working
-	cameraYaw.RotateY(e.yaw)
-	cameraPitch.RotateX(e.pitch)
+	// Without perspective 1/w is constant, orthographic views store 1 - z
+	if e.orthographic {
+		for i := range triangle.Attributes {
+			triangle.Attributes[i][AttributeW] = 1 - triangle.Vertices[i].Z
+		}
+	}
 
-	e.direction = cameraYaw.MulV(&target)
-	e.direction = cameraPitch.MulV(&e.direction)
-	target = e.camera.Add(&e.direction)
+	offsetView := Vector3d{1, 1, 0, 1}
+	for i := range triangle.Vertices {
+		// X/Y are inverted so put them back
+		triangle.Vertices[i].X *= -1.0
+		triangle.Vertices[i].Y *= -1.0
 
-	cameraMatrix := Identity4x4()
-	cameraMatrix.PointAt(&e.camera, &target, &up)
-	e.view = cameraMatrix.Inverse()
+		triangle.Vertices[i] = triangle.Vertices[i].Add(&offsetView)
+		triangle.Vertices[i].X *= 0.5 * e.W
+		triangle.Vertices[i].Y *= 0.5 * e.H
+	}
+	e.retro.snap(triangle)
 }
 
-// Update recalculates the world matrix
-func (e *Engine) update(m *Mesh) {
-	// Apply rotations and translations to the world matrix
-	m.world = Identity4x4()
-	m.world = m.world.MulM(&m.trans)
-	m.world = m.world.MulM(&m.rotX)
-	m.world = m.world.MulM(&m.rotY)
-	m.world = m.world.MulM(&m.rotZ)
-	m.world = m.world.MulM(&m.rotXAround)
-	m.world = m.world.MulM(&m.rotYAround)
-	m.world = m.world.MulM(&m.rotZAround)
-}
+// transformMesh transforms the triangles of a mesh into screen space. Triangles
+// facing away from the camera are culled and the rest is clipped against the near
+// plane. The result is appended to `trianglesToRaster`
+func (e *Engine) transformMesh(mesh *Mesh) {
+	receiveShadows := mesh.receiveShadows && len(e.lights) > 0
+	worldAttributes := receiveShadows || e.fog.heightFog()
+	for ti := range mesh.triangles {
+		triangle := &mesh.triangles[ti]
 
-// drawTriangle draw all pixels of a triangle. Supports textured and colored
-// triangles
-func (e *Engine) drawTriangle(triangle *Triangle, userData UserData) {
-	x1, y1, u1, v1, w1 := triangle.UnpackVertex(0)
-	x2, y2, u2, v2, w2 := triangle.UnpackVertex(1)
-	x3, y3, u3, v3, w3 := triangle.UnpackVertex(2)
+		// make copies of all triangle to avoid in place modification
+		triangleTransformed := Triangle{}
 
-	// Presort points depending on the shape of the triangle
-	if y2 < y1 {
-		y1, y2 = y2, y1
-		x1, x2 = x2, x1
-		u1, u2 = u2, u1
-		v1, v2 = v2, v1
-		w1, w2 = w2, w1
-	}
+		// Apply the world matrix
+		triangleTransformed.Vertices[0] = mesh.world.MulV(&triangle.Vertices[0])
+		triangleTransformed.Vertices[1] = mesh.world.MulV(&triangle.Vertices[1])
+		triangleTransformed.Vertices[2] = mesh.world.MulV(&triangle.Vertices[2])
 
-	if y3 < y1 {
-		y1, y3 = y3, y1
-		x1, x3 = x3, x1
-		u1, u3 = u3, u1
-		v1, v3 = v3, v1
-		w1, w3 = w3, w1
-	}
+		// Compute the normal for the triangle. They are used to determine if a triangle is visible
+		normal := triangleTransformed.Normal()
+		cameraRay := triangleTransformed.Vertices[0].Sub(&e.camera)
 
-	if y3 < y2 {
-		y2, y3 = y3, y2
-		x2, x3 = x3, x2
-		u2, u3 = u3, u2
-		v2, v3 = v3, v2
-		w2, w3 = w3, w2
-	}
+		// Is the triangle visible?
+		dp := normal.Dot(&cameraRay)
+		backface := dp >= 0
+		if backface {
+			e.Metrics.BackfaceCulled++
+		}
 
-	// Calculate offsets
-	dy1 := y2 - y1
-	dx1 := x2 - x1
-	dv1 := v2 - v1
-	du1 := u2 - u1
-	dw1 := w2 - w1
+		// The backface debug mode keeps culled triangles to highlight them, shadow
+		// maps keep them so meshes cast shadows regardless of their winding
+		if !backface || e.debugMode == DebugBackfaces || e.depthOnly {
+			triangleViewed := Triangle{}
 
-	dy2 := y3 - y1
-	dx2 := x3 - x1
-	dv2 := v3 - v1
-	du2 := u3 - u1
-	dw2 := w3 - w1
+			// Convert world space to view space
+			triangleViewed.Color = triangle.Color
+			triangleViewed.Texture = triangle.Texture
+			triangleViewed.colorAttributes = triangle.hasVertexColors()
+			triangleViewed.index = e.triangleIndex + ti
+			triangleViewed.backface = backface
+			triangleViewed.receiveShadows = receiveShadows
+			for i := range triangleViewed.Vertices {
+				triangleViewed.Vertices[i] = e.view.MulV(&triangleTransformed.Vertices[i])
+				triangleViewed.Attributes[i] = vertexAttributes(triangle, i)
+				if worldAttributes {
+					setWorldAttributes(&triangleViewed.Attributes[i], &triangleTransformed.Vertices[i])
+				}
+			}
 
-	daxStep := 0.0
-	dbxStep := 0.0
-	du1Step := 0.0
-	dv1Step := 0.0
-	dw1Step := 0.0
-	du2Step := 0.0
-	dv2Step := 0.0
-	dw2Step := 0.0
+			// Check if the triangles are intersecting with screen boundaries and need to be clipped
+			p0 := Vector3d{X: 0, Y: 0, Z: e.near}
+			p1 := Vector3d{X: 0, Y: 0, Z: 2.1}
+			clippedTriangles := [2]Triangle{}
+			numberOfClippedTriangles := triangleViewed.ClipAgainstPlane(&p0, &p1, &clippedTriangles[0], &clippedTriangles[1])
+			if numberOfClippedTriangles != 1 || clippedTriangles[0].Vertices != triangleViewed.Vertices {
+				e.Metrics.NearClipped++
+			}
 
-	// Calculate steps
-	if dy1 >= 0 {
-		daxStep = float64(dx1) / math.Abs(float64(dy1))
-	}
+			for n := 0; n < numberOfClippedTriangles; n++ {
+				triangleProjected := &clippedTriangles[n]
 
-	if dy2 >= 0 {
-		dbxStep = float64(dx2) / math.Abs(float64(dy2))
-	}
+				// Project from 3D into 2D
+				triangleProjected.Vertices[0] = e.projection.MulV(&triangleProjected.Vertices[0])
+				triangleProjected.Vertices[1] = e.projection.MulV(&triangleProjected.Vertices[1])
+				triangleProjected.Vertices[2] = e.projection.MulV(&triangleProjected.Vertices[2])
+				e.projectToScreen(triangleProjected)
 
-	if dy1 >= 0 {
-		du1Step = du1 / math.Abs(float64(dy1))
-	}
-	if dy1 >= 0 {
-		dv1Step = dv1 / math.Abs(float64(dy1))
-	}
-	if dy1 >= 0 {
-		dw1Step = dw1 / math.Abs(float64(dy1))
+				// The triangle is ready for rendering
+				e.trianglesToRaster = append(e.trianglesToRaster, *triangleProjected)
+			}
+		}
 	}
+}
 
-	if dy2 >= 0 {
-		du2Step = du2 / math.Abs(float64(dy2))
-	}
-	if dy2 >= 0 {
-		dv2Step = dv2 / math.Abs(float64(dy2))
-	}
-	if dy2 >= 0 {
-		dw2Step = dw2 / math.Abs(float64(dy2))
+// renderMesh renders a single mesh
+func (e *Engine) renderMesh(mesh *Mesh, userData UserData) int {
+	start := time.Now()
+	e.triangleIndex = e.Metrics.InputTriangles
+	e.Metrics.InputTriangles += len(mesh.triangles)
+
+	// Scratch buffers are reused, so a static scene renders without allocations
+	// when the output goes to a span hook
+	e.trianglesToRaster = e.trianglesToRaster[:0]
+	// Shadow maps ignore vertex shaders like the shadow lookup of receivers does
+	uniforms := e.meshUniforms(mesh)
+	if uniforms != nil && !e.depthOnly {
+		e.shadeMesh(mesh, uniforms)
+	} else {
+		e.transformMesh(mesh)
 	}
 
-	if dy1 >= 0 {
-		if triangle.Color != nil {
-			for i := y1; i <= y2; i++ {
-				ax := float64(x1) + float64(i-y1)*daxStep
-				bx := float64(x1) + float64(i-y1)*dbxStep
-				texSw := w1 + float64(i-y1)*dw1Step
-				texEw := w1 + float64(i-y1)*dw2Step
-
-				if ax > bx {
-					ax, bx = bx, ax
-					texSw, texEw = texEw, texSw
-				}
-
-				texW := texSw
-				tStep := 1.0 / (bx - ax)
-				t := 0.0
+	transformed := time.Now()
+	e.Metrics.TransformTime += transformed.Sub(start)
 
-				for j := ax; j < bx; j++ {
-					texW = (1.0-t)*texSw + t*texEw
+	// The edge function core samples pixel centers, so the last row and column
+	// of the screen need the whole pixel
+	right, bottom := e.W-1, e.H-1
+	if e.rasterizer == RasterizerEdgeFunction {
+		right, bottom = e.W, e.H
+	}
 
-					if texW > e.depthBuffer.At(int(j), i) {
-						e.drawPixel(int(j), i, triangle.Color, userData)
-						e.depthBuffer.Set(int(j), i, texW)
-					}
+	// Screen boundaries as point and normal
+	planes := [4][2]Vector3d{
+		{{0, 0, 0, 1}, {0, 1, 0, 1}},
+		{{0, bottom, 0, 1}, {0, -1, 0, 1}},
+		{{0, 0, 0, 1}, {1, 0, 0, 1}},
+		{{right, 0, 0, 1}, {-1, 0, 0, 1}},
+	}
 
-					t += tStep
-				}
+	e.rasterQueue = e.rasterQueue[:0]
+	for ti := range e.trianglesToRaster {
+		clipped := [2]Triangle{}
+		triangle := &e.trianglesToRaster[ti]
+
+		// Every plane clips the triangles of the current list into the next one
+		e.clipCurrent = append(e.clipCurrent[:0], *triangle)
+		for p := range planes {
+			e.clipNext = e.clipNext[:0]
+			for i := range e.clipCurrent {
+				trianglesToAdd := e.clipCurrent[i].ClipAgainstPlane(&planes[p][0], &planes[p][1], &clipped[0], &clipped[1])
+				e.clipNext = append(e.clipNext, clipped[:trianglesToAdd]...)
 			}
-		} else if e.textureAtlas != nil {
-			for i := y1; i <= y2; i++ {
-				ax := float64(x1) + float64(i-y1)*daxStep
-				bx := float64(x1) + float64(i-y1)*dbxStep
-
-				texSu := u1 + float64(i-y1)*du1Step
-				texSv := v1 + float64(i-y1)*dv1Step
-				texSw := w1 + float64(i-y1)*dw1Step
-
-				texEu := u1 + float64(i-y1)*du2Step
-				texEv := v1 + float64(i-y1)*dv2Step
-				texEw := w1 + float64(i-y1)*dw2Step
-
-				if ax > bx {
-					ax, bx = bx, ax
-					texSu, texEu = texEu, texSu
-					texSv, texEv = texEv, texSv
-					texSw, texEw = texEw, texSw
-				}
+			e.clipCurrent, e.clipNext = e.clipNext, e.clipCurrent
+		}
 
-				texU := texSu
-				texV := texSv
-				texW := texSw
-
-				tStep := 1.0 / (bx - ax)
-				t := 0.0
-
-				for j := ax; j < bx; j++ {
-					texU = (1.0-t)*texSu + t*texEu
-					texV = (1.0-t)*texSv + t*texEv
-					texW = (1.0-t)*texSw + t*texEw
-
-					textureWidth := float64(e.textureAtlas.W() - 1)
-					textureHeight := float64(e.textureAtlas.H() - 1)
-
-					if texW > e.depthBuffer.At(int(j), i) {
-						textureX := int((texU / texW) * textureWidth)
-						textureY := 0
-						if e.yOrigin == YOriginUpperLeft {
-							textureY = int((texV / texW) * textureHeight)
-						} else {
-							textureY = int((1 - texV/texW) * textureHeight) // invert Y to conform with blender origin
-						}
-						e.drawPixel(int(j), i, e.textureAtlas.ColorAt(textureX, textureY), userData)
-						e.depthBuffer.Set(int(j), i, texW)
-					}
-					t += tStep
-				}
-			}
-		} else {
-			panic("draw error: neither textureAtlas nor color defined")
+		if len(e.clipCurrent) != 1 || e.clipCurrent[0].Vertices != triangle.Vertices {
+			e.Metrics.ScreenClipped++
+			e.Metrics.ScreenClippedOutput += len(e.clipCurrent)
 		}
+		e.rasterQueue = append(e.rasterQueue, e.clipCurrent...)
 	}
 
-	dy1 = y3 - y2
-	dx1 = x3 - x2
-	dv1 = v3 - v2
-	du1 = u3 - u2
-	dw1 = w3 - w2
+	clipped := time.Now()
+	e.Metrics.ClippingTime += clipped.Sub(transformed)
 
-	if dy1 >= 0 {
-		daxStep = float64(dx1) / math.Abs(float64(dy1))
+	// Painter's sorting draws the triangles once all meshes are queued
+	if e.retro.painterSort() {
+		e.queuePainter(mesh)
+		return len(e.rasterQueue)
 	}
 
-	if dy2 >= 0 {
-		dbxStep = float64(dx2) / math.Abs(float64(dy2))
+	for i := range e.rasterQueue {
+		e.drawTriangle(&e.rasterQueue[i], mesh.material, uniforms, userData)
 	}
 
-	du1Step = 0
-	dv1Step = 0
+	e.Metrics.RasterizationTime += time.Since(clipped)
+	return len(e.rasterQueue)
+}
 
-	if dy1 >= 0 {
-		du1Step = du1 / math.Abs(float64(dy1))
-	}
-	if dy1 >= 0 {
-		dv1Step = dv1 / math.Abs(float64(dy1))
+// meshUniforms returns the uniforms of a mesh with a material, nil otherwise
+func (e *Engine) meshUniforms(mesh *Mesh) *Uniforms {
+	if mesh.material == nil {
+		return nil
 	}
-	if dy1 >= 0 {
-		dw1Step = dw1 / math.Abs(float64(dy1))
+	e.uniforms = Uniforms{
+		World:      mesh.world,
+		View:       e.view,
+		Projection: e.projection,
+		Camera:     e.camera,
 	}
+	return &e.uniforms
+}
 
-	if dy1 >= 0 {
-		if triangle.Color != nil {
-			for i := y2; i <= y3; i++ {
-				ax := float64(x2) + float64(i-y2)*daxStep
-				bx := float64(x1) + float64(i-y1)*dbxStep
-				texSw := w2 + float64(i-y2)*dw1Step
-				texEw := w1 + float64(i-y1)*dw2Step
-
-				if ax > bx {
-					ax, bx = bx, ax
-					texSw, texEw = texEw, texSw
-				}
+// renderPoints renders the points of a mesh as single pixels
+func (e *Engine) renderPoints(mesh *Mesh, userData UserData) {
+	for _, point := range mesh.points {
+		position := point.Position
+		position.W = 1
 
-				texW := texSw
-				tStep := 1.0 / (bx - ax)
-				t := 0.0
-
-				for j := ax; j < bx; j++ {
-					texW = (1.0-t)*texSw + t*texEw
-					if texW > e.depthBuffer.At(int(j), i) {
-						e.drawPixel(int(j), i, triangle.Color, userData)
-						e.depthBuffer.Set(int(j), i, texW)
-					}
-					t += tStep
-				}
-			}
+		// Apply the world matrix and convert world space to view space
+		transformed := mesh.world.MulV(&position)
+		viewed := e.view.MulV(&transformed)
 
-		} else if e.textureAtlas != nil {
-			for i := y2; i <= y3; i++ {
-				ax := float64(x2) + float64(i-y2)*daxStep
-				bx := float64(x1) + float64(i-y1)*dbxStep
-
-				texSu := u2 + float64(i-y2)*du1Step
-				texSv := v2 + float64(i-y2)*dv1Step
-				texSw := w2 + float64(i-y2)*dw1Step
+		// Points behind the near plane are not visible
+		if viewed.Z < e.near {
+			continue
+		}
 
-				texEu := u1 + float64(i-y1)*du2Step
-				texEv := v1 + float64(i-y1)*dv2Step
-				texEw := w1 + float64(i-y1)*dw2Step
+		// Project from 3D into 2D, X/Y are inverted so put them back
+		projected := e.projection.MulV(&viewed)
+		x := int((1 - projected.X/projected.W) * 0.5 * e.W)
+		y := int((1 - projected.Y/projected.W) * 0.5 * e.H)
+		if x < 0 || y < 0 || x >= e.w || y >= e.h {
+			continue
+		}
 
-				if ax > bx {
-					ax, bx = bx, ax
-					texSu, texEu = texEu, texSu
-					texSv, texEv = texEv, texSv
-					texSw, texEw = texEw, texSw
+		// Same depth value as the interpolated W of triangles. With anti-aliasing
+		// the point covers all samples of its pixel
+		depth := 1 / projected.W
+		scale := e.sampleScale()
+		x0, y0 := x/scale*scale, y/scale*scale
+		for y := y0; y < y0+scale; y++ {
+			for x := x0; x < x0+scale; x++ {
+				if depth <= e.depthBuffer.At(x, y) {
+					e.Metrics.PixelsRejected++
+					continue
 				}
-
-				texU := texSu
-				texV := texSv
-				texW := texSw
-
-				tStep := 1.0 / (bx - ax)
-				t := 0.0
-
-				for j := ax; j < bx; j++ {
-					texU = (1.0-t)*texSu + t*texEu
-					texV = (1.0-t)*texSv + t*texEv
-					texW = (1.0-t)*texSw + t*texEw
-
-					textureWidth := float64(e.textureAtlas.W() - 1)
-					textureHeight := float64(e.textureAtlas.H() - 1)
-
-					if texW > e.depthBuffer.At(int(j), i) {
-						textureX := int((texU / texW) * textureWidth)
-						textureY := 0
-						if e.yOrigin == YOriginUpperLeft {
-							textureY = int((texV / texW) * textureHeight)
-						} else {
-							textureY = int((1 - texV/texW) * textureHeight) // invert Y to conform with blender origin
-						}
-						e.drawPixel(int(j), i, e.textureAtlas.ColorAt(textureX, textureY), userData)
-						e.depthBuffer.Set(int(j), i, texW)
-					}
-
-					t += tStep
+				var c color.Color = color.White
+				if point.Color != nil {
+					c = point.Color
+				}
+				if e.fog != nil {
+					c = e.fog.apply(toRGBA64(c), projected.W, transformed.Y)
 				}
+				if e.debugBuffered() {
+					e.debugPixel(x, y)
+				} else {
+					e.plot(x, y, c, userData)
+				}
+				e.depthBuffer.Set(x, y, depth)
+				e.Metrics.PixelsWritten++
 			}
-		} else {
-			panic("draw error: neither textureAtlas nor color defined")
+			e.flushSpan(userData)
 		}
 	}
 }
 
-// renderMesh renders a single mesh
-func (e *Engine) renderMesh(mesh *Mesh, userData UserData) int {
-	// trianglesToRaster holds all visible triangles
-	var trianglesToRaster []Triangle
-	totalTrianglesRendered := 0
-
-	for _, triangle := range mesh.triangles {
-		// make copies of all triangle to avoid in place modification
-		triangleTransformed := Triangle{}
-
-		// Apply the world matrix
-		triangleTransformed.UVs = triangle.UVs.Copy()
-		triangleTransformed.Color = triangle.Color
-		triangleTransformed.Vertices[0] = mesh.world.MulV(&triangle.Vertices[0])
-		triangleTransformed.Vertices[1] = mesh.world.MulV(&triangle.Vertices[1])
-		triangleTransformed.Vertices[2] = mesh.world.MulV(&triangle.Vertices[2])
-
-		// Compute the normal for the triangle. They are used to determine if a triangle is visible
-		normal := triangleTransformed.Normal()
-		cameraRay := triangleTransformed.Vertices[0].Sub(&e.camera)
-
-		// Is the triangle visible?
-		dp := normal.Dot(&cameraRay)
-		if dp < 0 {
-			triangleViewed := Triangle{}
+// Render renders all meshes
+func (e *Engine) Render(userData UserData) {
+	e.frame++
+	e.render(userData, renderPass{engine: e, frame: e.frame})
+}
 
-			// Convert world space to view space
-			triangleViewed.UVs = triangleTransformed.UVs.Copy()
-			triangleViewed.Color = triangleTransformed.Color
-			triangleViewed.Vertices[0] = e.view.MulV(&triangleTransformed.Vertices[0])
-			triangleViewed.Vertices[1] = e.view.MulV(&triangleTransformed.Vertices[1])
-			triangleViewed.Vertices[2] = e.view.MulV(&triangleTransformed.Vertices[2])
+// render renders a frame as part of a pass. Render textures used in the frame are
+// rendered first
+func (e *Engine) render(userData UserData, pass renderPass) {
+	start := time.Now()
+	e.renderTextures(pass)
+	e.Metrics.beginFrame()
+	if e.palette != nil {
+		clear(e.palette.frame)
+	}
 
-			// Check if the triangles are intersecting with screen boundaries and need to be clipped
-			p0 := Vector3d{X: 0, Y: 0, Z: 0.1}
-			p1 := Vector3d{X: 0, Y: 0, Z: 2.1}
-			clippedTriangles := [2]Triangle{}
-			numberOfClippedTriangles := triangleViewed.ClipAgainstPlane(&p0, &p1, &clippedTriangles[0], &clippedTriangles[1])
+	for _, mesh := range e.meshes {
+		mesh.updateWorld()
+	}
+	if len(e.lights) > 0 {
+		e.renderShadowMaps()
+	}
 
-			for n := 0; n < numberOfClippedTriangles; n++ {
-				triangleProjected := Triangle{}
-				triangleProjected.UVs = clippedTriangles[n].UVs.Copy()
+	// The hooks are replaced from the output inwards: dithering, post effects and
+	// anti-aliasing each hand their result to the previous hook
+	drawSpan := e.drawSpan
+	if e.retro.dithering() {
+		e.ditherOutput = drawSpan
+		e.drawSpan = e.ditherHook
+	}
+	outputSpan := e.drawSpan
+
+	// Post effects need the whole frame, it is rendered into the frame buffer
+	postProcessing := len(e.postEffects) > 0
+	if postProcessing {
+		clear(e.frameBuffer.Color)
+		clear(e.frameBuffer.Depth)
+		e.drawSpan = e.bufferSpan
+	}
 
-				// Project from 3D into 2D
-				triangleProjected.Vertices[0] = e.projection.MulV(&clippedTriangles[n].Vertices[0])
-				triangleProjected.Vertices[1] = e.projection.MulV(&clippedTriangles[n].Vertices[1])
-				triangleProjected.Vertices[2] = e.projection.MulV(&clippedTriangles[n].Vertices[2])
-
-				triangleProjected.UVs.ScaleW(&triangleProjected)
-				triangleProjected.ScaleW()
-				triangleProjected.Color = clippedTriangles[n].Color
-
-				// X/Y are inverted so put them back
-				triangleProjected.Vertices[0].X *= -1.0
-				triangleProjected.Vertices[1].X *= -1.0
-				triangleProjected.Vertices[2].X *= -1.0
-				triangleProjected.Vertices[0].Y *= -1.0
-				triangleProjected.Vertices[1].Y *= -1.0
-				triangleProjected.Vertices[2].Y *= -1.0
-
-				offsetView := Vector3d{1, 1, 0, 1}
-
-				triangleProjected.Vertices[0] = triangleProjected.Vertices[0].Add(&offsetView)
-				triangleProjected.Vertices[1] = triangleProjected.Vertices[1].Add(&offsetView)
-				triangleProjected.Vertices[2] = triangleProjected.Vertices[2].Add(&offsetView)
-
-				triangleProjected.Vertices[0].X *= 0.5 * e.W
-				triangleProjected.Vertices[0].Y *= 0.5 * e.H
-				triangleProjected.Vertices[1].X *= 0.5 * e.W
-				triangleProjected.Vertices[1].Y *= 0.5 * e.H
-				triangleProjected.Vertices[2].X *= 0.5 * e.W
-				triangleProjected.Vertices[2].Y *= 0.5 * e.H
+	// With anti-aliasing the frame is rendered into the samples first
+	resolveSpan := e.drawSpan
+	if e.samples != nil {
+		clear(e.samples.colors)
+		e.drawSpan = e.samples.hook
+	}
 
-				// The triangle is ready for rendering
-				trianglesToRaster = append(trianglesToRaster, triangleProjected)
-			}
+	totalTrianglesRendered := 0
+	if len(e.viewports) == 0 {
+		totalTrianglesRendered = e.renderView(userData)
+	} else {
+		// Every viewport takes the place of the camera of the engine while it
+		// is rendered
+		target := e.viewState
+		for _, viewport := range e.viewports {
+			e.viewState = viewport.viewState
+			totalTrianglesRendered += e.renderView(userData)
+			viewport.viewState = e.viewState
 		}
+		e.viewState = target
 	}
 
-	for _, triangle := range trianglesToRaster {
-		clipped := [2]Triangle{}
-		var finalTrianglesList = []Triangle{triangle}
-		newTriangles := 1
-
-		for p := 0; p < 4; p++ {
-			trianglesToAdd := 0
-			for newTriangles > 0 {
-				test := finalTrianglesList[0]
-				finalTrianglesList = finalTrianglesList[1:]
-				newTriangles--
-
-				switch p {
-				case 0:
-					trianglesToAdd = test.ClipAgainstPlane(&Vector3d{0, 0, 0, 1}, &Vector3d{0, 1, 0, 1}, &clipped[0], &clipped[1])
-					break
-				case 1:
-					trianglesToAdd = test.ClipAgainstPlane(&Vector3d{0, e.H - 1, 0, 1}, &Vector3d{0, -1, 0, 1}, &clipped[0], &clipped[1])
-					break
-				case 2:
-					trianglesToAdd = test.ClipAgainstPlane(&Vector3d{0, 0, 0, 1}, &Vector3d{1, 0, 0, 1}, &clipped[0], &clipped[1])
-					break
-				case 3:
-					trianglesToAdd = test.ClipAgainstPlane(&Vector3d{e.W - 1, 0, 0, 1}, &Vector3d{-1, 0, 0, 1}, &clipped[0], &clipped[1])
-					break
-				}
+	if e.samples != nil {
+		e.drawSpan = resolveSpan
+		e.resolveSamples(userData)
+	}
 
-				for w := 0; w < trianglesToAdd; w++ {
-					finalTrianglesList = append(finalTrianglesList, clipped[w])
-				}
-			}
-			newTriangles = len(finalTrianglesList)
-		}
+	if postProcessing {
+		e.drawSpan = outputSpan
+		e.postProcess(userData)
+	}
+	e.drawSpan = drawSpan
 
-		for _, t := range finalTrianglesList {
-			e.drawTriangle(&t, userData)
-			totalTrianglesRendered++
-		}
+	if e.palette != nil {
+		e.resolvePalette(userData)
 	}
 
-	return totalTrianglesRendered
+	e.Metrics.Triangles = totalTrianglesRendered
+	e.Metrics.endFrame(time.Since(start))
 }
 
-// Render renders all meshes
-func (e *Engine) Render(userData UserData) {
-	start := time.Now().UnixMilli()
-
+// renderView renders all meshes from the current camera into its rectangle
+func (e *Engine) renderView(userData UserData) int {
 	e.depthBuffer.Clear()
+	if e.debugMode == DebugOverdraw {
+		clear(e.debugCounts)
+	}
 	e.updateCamera()
 
+	// Without depth buffer the background is painted over
+	painter := e.retro.painterSort()
+	background := e.background != nil && !e.debugBuffered()
+	if background && painter {
+		e.renderBackground(userData)
+	}
+
+	points := func(mesh *Mesh) {
+		pointsStart := time.Now()
+		e.renderPoints(mesh, userData)
+		e.Metrics.RasterizationTime += time.Since(pointsStart)
+	}
+
 	totalTrianglesRendered := 0
 	for _, mesh := range e.meshes {
-		e.update(mesh)
 		totalTrianglesRendered += e.renderMesh(mesh, userData)
+		if !painter {
+			points(mesh)
+		}
 	}
 
-	finish := time.Now().UnixMilli()
-	e.Metrics.RenderTime = finish - start
-	e.Metrics.Triangles = totalTrianglesRendered
+	for _, terrain := range e.terrains {
+		for _, mesh := range terrain.Select(&e.camera) {
+			mesh.updateWorld()
+			totalTrianglesRendered += e.renderMesh(mesh, userData)
+		}
+	}
+
+	if painter {
+		e.drawPainter(userData)
+		for _, mesh := range e.meshes {
+			points(mesh)
+		}
+	}
+
+	// Pixels covered by geometry have a depth, the background fills the rest
+	if background && !painter {
+		e.renderBackground(userData)
+	}
+
+	if e.debugBuffered() {
+		e.resolveDebug(userData)
+	}
+
+	if len(e.postEffects) > 0 {
+		e.copyDepth()
+	}
+	return totalTrianglesRendered
 }
 
 // ToRadians converts degrees to radians
@@ -543,21 +523,26 @@ func ToRadians(degrees float64) float64 {
 // NewEngine creates a new 3d engine instance with the given internal
 // width and height
 func NewEngine(w, h int, fovDegrees float64, drawHook DrawHook, opts *EngineOptions) *Engine {
-	engine := &Engine{w: w, h: h, W: float64(w), H: float64(h)}
+	// Anti-aliasing renders at the resolution of the samples
+	antiAliasing := opts.GetAntiAliasing()
+	scale := antiAliasing.scale()
+	engine := &Engine{viewState: newViewState(0, 0, w*scale, h*scale, fovDegrees)}
+	if antiAliasing != AntiAliasingNone {
+		engine.samples = newSampleBuffer(antiAliasing, w, h)
+	}
 	engine.meshes = make([]*Mesh, 0)
-	engine.camera = Vector3d{
-		X: 0,
-		Y: 0,
-		Z: 0,
-		W: 1,
-	}
-	aspectRatio := float64(w) / float64(h)
-	fov := 1.0 / math.Tan(ToRadians(fovDegrees/2))
-	engine.projection = Projection4x4(fov, aspectRatio, 0.1, 1000)
-	engine.depthBuffer = NewDepthBuffer(w, h)
 	engine.drawPixel = drawHook
+	engine.drawSpan = opts.GetSpanHook()
+	if paletteHook := opts.GetPaletteHook(); paletteHook != nil {
+		engine.palette = newPaletteBuffer(opts.GetPalette(), opts.GetPaletteDither(), paletteHook, w, h)
+		engine.drawSpan = engine.palette.hook
+	}
+	engine.spanBuffer = make([]color.RGBA, 0, w*scale)
+	engine.Metrics = newMetrics(opts.GetMetricsFrames())
 	engine.yOrigin = opts.GetYOrigin()
 	engine.textureAtlas = opts.GetTextureAtlas()
+	engine.rasterizer = opts.GetRasterizer()
+	engine.subPixelBits = opts.GetSubPixelBits()
 
 	return engine
 }
@@ -1,5 +1,7 @@
 package api
 
+import "image/color"
+
 type YOrigin int
 
 const (
@@ -7,9 +9,44 @@ const (
 	YOriginLowerLeft         // Blender exports UV coordinates with the origin in the lower left
 )
 
+// Default and maximum number of fractional bits of vertex positions used by
+// `RasterizerEdgeFunction`
+const (
+	DefaultSubPixelBits = 4
+	MaxSubPixelBits     = 8
+)
+
 type EngineOptions struct {
 	TextureAtlas TextureAtlas
 	YOrigin      YOrigin
+
+	// Rasterizer selects the rasterization core, `RasterizerScanline` by default
+	Rasterizer Rasterizer
+
+	// SubPixelBits is the number of fractional bits of vertex positions for
+	// `RasterizerEdgeFunction`. Zero selects `DefaultSubPixelBits`, values above
+	// `MaxSubPixelBits` are clamped
+	SubPixelBits int
+
+	// AntiAliasing smooths the edges of triangles, `AntiAliasingNone` by default.
+	// The depth buffer, the screen and the positions seen by fragment shaders
+	// are scaled to the samples, the hooks still receive pixels of the target
+	AntiAliasing AntiAliasing
+
+	// SpanHook receives whole runs of pixels instead of calling the draw hook
+	// for every pixel. The draw hook may be nil if this is set
+	SpanHook SpanHook
+
+	// PaletteHook receives the index of a color of Palette for every pixel
+	// instead of calling the draw or span hook. The frame is collected first and
+	// quantized with PaletteDither once it is complete
+	PaletteHook   PaletteHook
+	Palette       color.Palette
+	PaletteDither PaletteDither
+
+	// MetricsFrames is the number of recent frames covered by the rolling frame
+	// time statistics of `Metrics`. Defaults to `DefaultMetricsFrames`
+	MetricsFrames int
 }
 
 func (e *EngineOptions) GetYOrigin() YOrigin {
@@ -25,3 +62,59 @@ func (e *EngineOptions) GetTextureAtlas() TextureAtlas {
 	}
 	return e.TextureAtlas
 }
+
+func (e *EngineOptions) GetRasterizer() Rasterizer {
+	if e == nil {
+		return RasterizerScanline
+	}
+	return e.Rasterizer
+}
+
+func (e *EngineOptions) GetSubPixelBits() int {
+	if e == nil || e.SubPixelBits <= 0 {
+		return DefaultSubPixelBits
+	}
+	return min(e.SubPixelBits, MaxSubPixelBits)
+}
+
+func (e *EngineOptions) GetAntiAliasing() AntiAliasing {
+	if e == nil {
+		return AntiAliasingNone
+	}
+	return e.AntiAliasing
+}
+
+func (e *EngineOptions) GetSpanHook() SpanHook {
+	if e == nil {
+		return nil
+	}
+	return e.SpanHook
+}
+
+func (e *EngineOptions) GetPaletteHook() PaletteHook {
+	if e == nil {
+		return nil
+	}
+	return e.PaletteHook
+}
+
+func (e *EngineOptions) GetPalette() color.Palette {
+	if e == nil {
+		return nil
+	}
+	return e.Palette
+}
+
+func (e *EngineOptions) GetPaletteDither() PaletteDither {
+	if e == nil {
+		return PaletteDitherNone
+	}
+	return e.PaletteDither
+}
+
+func (e *EngineOptions) GetMetricsFrames() int {
+	if e == nil || e.MetricsFrames <= 0 {
+		return DefaultMetricsFrames
+	}
+	return e.MetricsFrames
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// benchmarkScene returns an engine with a static scene of colored and vertex
+// colored meshes, some of them clipped by the screen edges and the near plane
+func benchmarkScene(drawHook DrawHook, opts *EngineOptions) *Engine {
+	engine := NewEngine(320, 240, 90, drawHook, opts)
+
+	cube := ColoredCube()
+	cube.SetMeshPositionRelative(-0.5, -0.5, 1.5)
+	engine.AddMesh(cube)
+
+	sphere := Icosphere(1, 2)
+	for i := range sphere.triangles {
+		sphere.triangles[i].VertexColors = [3]color.Color{color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}, color.RGBA{B: 255, A: 255}}
+	}
+	sphere.SetMeshPositionRelative(1.5, 0, 3)
+	engine.AddMesh(sphere)
+
+	floor := Plane(20, 20, 8, 8)
+	for i := range floor.triangles {
+		floor.triangles[i].Color = color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	}
+	floor.SetMeshPositionRelative(0, -1, 0)
+	engine.AddMesh(floor)
+
+	shaded := ColoredCube()
+	shaded.SetMeshPositionRelative(-2, 0, 3)
+	shaded.SetMaterial(&Material{
+		VertexShader: func(in *VertexInput, out *Varyings) Vector3d {
+			out[0] = in.Position.Y
+			return DefaultVertexShader(in, out)
+		},
+		FragmentShader: func(in *FragmentInput) (color.Color, bool) {
+			return in.Color, in.Varyings[0] >= 0
+		},
+	})
+	engine.AddMesh(shaded)
+
+	engine.SetCameraPositionAbsolute(0, 0.5, -1, 0.2, 0.1)
+	return engine
+}
+
+func noopDrawHook(x, y int, c color.Color, userData UserData) {}
+
+func noopSpanHook(y, x0, x1 int, colors []color.RGBA, userData UserData) {}
+
+func benchmarkRender(b *testing.B, engine *Engine) {
+	b.ReportAllocs()
+	engine.Render(nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.Render(nil)
+	}
+}
+
+func BenchmarkEngine_Render(b *testing.B) {
+	benchmarkRender(b, benchmarkScene(noopDrawHook, nil))
+}
+
+func BenchmarkEngine_RenderSpanHook(b *testing.B) {
+	benchmarkRender(b, benchmarkScene(nil, &EngineOptions{SpanHook: noopSpanHook}))
+}
+
+func BenchmarkEngine_RenderEdgeFunction(b *testing.B) {
+	benchmarkRender(b, benchmarkScene(nil, &EngineOptions{SpanHook: noopSpanHook, Rasterizer: RasterizerEdgeFunction}))
+}
+
+// The draw hook path allocates interpolated colors, only spans are checked
+func TestEngine_RenderZeroAllocations(t *testing.T) {
+	for _, rasterizer := range []Rasterizer{RasterizerScanline, RasterizerEdgeFunction} {
+		engine := benchmarkScene(nil, &EngineOptions{SpanHook: noopSpanHook, Rasterizer: rasterizer})
+
+		// Warm up the scratch buffers
+		engine.Render(nil)
+		if engine.Metrics.Triangles == 0 {
+			t.Fatalf("scene is not visible")
+		}
+
+		if allocs := testing.AllocsPerRun(10, func() { engine.Render(nil) }); allocs != 0 {
+			t.Fatalf("expected no allocations per frame, got %v", allocs)
+		}
+	}
+}
+
+func TestEngine_RenderPaletteZeroAllocations(t *testing.T) {
+	palette := color.Palette{}
+	for i := 0; i < 64; i++ {
+		palette = append(palette, color.RGBA{R: uint8(i&3) * 85, G: uint8(i>>2&3) * 85, B: uint8(i>>4) * 85, A: 255})
+	}
+
+	for _, dither := range []PaletteDither{PaletteDitherNone, PaletteDitherFloydSteinberg, PaletteDitherBayer} {
+		engine := benchmarkScene(nil, &EngineOptions{
+			Palette:       palette,
+			PaletteDither: dither,
+			PaletteHook:   func(x, y int, index uint8, userData UserData) {},
+		})
+		engine.Render(nil)
+
+		if allocs := testing.AllocsPerRun(10, func() { engine.Render(nil) }); allocs != 0 {
+			t.Fatalf("dither %d: expected no allocations per frame, got %v", dither, allocs)
+		}
+	}
+}
+
+func BenchmarkEngine_RenderCubes(b *testing.B) {
+	for _, n := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("cubes-%d", n), func(b *testing.B) {
+			engine := NewEngine(320, 240, 90, nil, &EngineOptions{SpanHook: noopSpanHook})
+
+			// A grid of cubes in front of the camera
+			side := int(math.Ceil(math.Sqrt(float64(n))))
+			for i := 0; i < n; i++ {
+				cube := ColoredCube()
+				cube.SetMeshPositionRelative(float64(i%side)*2-float64(side), -0.5, float64(i/side)*2+3)
+				engine.AddMesh(cube)
+			}
+			engine.SetCameraPositionAbsolute(0, 2, -2, 0, 0.3)
+			benchmarkRender(b, engine)
+		})
+	}
+}
+
+func BenchmarkEngine_RenderHighPolyObj(b *testing.B) {
+	filename := writeHighPolyObj(b)
+	mesh, err := LoadWavefrontObj(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := range mesh.triangles {
+		mesh.triangles[i].Color = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+	}
+	mesh.SetMeshPositionRelative(0, 0, 3)
+
+	engine := NewEngine(320, 240, 90, nil, &EngineOptions{SpanHook: noopSpanHook})
+	engine.AddMesh(mesh)
+	benchmarkRender(b, engine)
+}
+
+func TestEngine_Metrics(t *testing.T) {
+	pixels := 0
+	engine := benchmarkScene(func(x, y int, c color.Color, userData UserData) {
+		pixels++
+	}, &EngineOptions{MetricsFrames: 2})
+
+	for i := 0; i < 3; i++ {
+		engine.Render(nil)
+	}
+
+	m := &engine.Metrics
+	input := 0
+	for _, mesh := range engine.meshes {
+		input += len(mesh.triangles)
+	}
+	if m.InputTriangles != input {
+		t.Fatalf("expected %d input triangles, got %d", input, m.InputTriangles)
+	}
+	if m.BackfaceCulled == 0 || m.NearClipped == 0 || m.ScreenClipped == 0 {
+		t.Fatalf("missing triangle counts: %+v", m)
+	}
+	if m.PixelsWritten != pixels/3 || m.PixelsRejected == 0 {
+		t.Fatalf("expected %d written pixels, got %d (%d rejected)", pixels/3, m.PixelsWritten, m.PixelsRejected)
+	}
+	if m.FrameTime <= 0 || m.TransformTime <= 0 || m.RasterizationTime <= 0 || m.FlushTime != 0 {
+		t.Fatalf("unexpected timings: %+v", m)
+	}
+	if m.Frames() != 2 {
+		t.Fatalf("expected statistics over 2 frames, got %d", m.Frames())
+	}
+	if m.AverageFrameTime() <= 0 || m.FrameTimePercentile(50) > m.FrameTimePercentile(100) {
+		t.Fatalf("invalid frame time statistics")
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"image/color"
+	"math"
+)
+
+// FogMode selects how fog thickens with the distance from the camera
+type FogMode int
+
+const (
+	// FogLinear blends from nothing at `Fog.Start` to the fog color at `Fog.End`
+	FogLinear FogMode = iota
+
+	// FogExponential hides 1 - e^(-density * distance) of the color
+	FogExponential
+
+	// FogExponentialSquared hides 1 - e^(-(density * distance)^2) of the color,
+	// which keeps the near range clearer than exponential fog
+	FogExponentialSquared
+)
+
+// Fog blends pixels into a color with growing distance from the camera, so
+// distant geometry fades into the sky instead of popping at the far plane
+type Fog struct {
+	Mode FogMode
+
+	// Color of the fog, usually the color of the sky. Black if not set
+	Color color.Color
+
+	// Distances where linear fog begins and where it hides everything
+	Start, End float64
+
+	// Density of exponential fog
+	Density float64
+
+	// HeightFalloff thins out the fog above the world height `Height`, the
+	// amount of fog drops to 1/e every 1 / HeightFalloff units. Zero disables it
+	Height        float64
+	HeightFalloff float64
+}
+
+// SetFog enables fog, nil turns it off
+func (e *Engine) SetFog(fog *Fog) {
+	e.fog = fog
+}
+
+// Fog returns the current fog, nil if there is none
+func (e *Engine) Fog() *Fog {
+	return e.fog
+}
+
+// heightFog returns true if the fog depends on the world height of pixels
+func (f *Fog) heightFog() bool {
+	return f != nil && f.HeightFalloff > 0
+}
+
+// amount returns the share of the fog color at a pixel with the given view depth
+// and world height, from 0 to 1
+func (f *Fog) amount(distance, height float64) float64 {
+	amount := 0.0
+	switch f.Mode {
+	case FogLinear:
+		if f.End > f.Start {
+			amount = (distance - f.Start) / (f.End - f.Start)
+		} else if distance >= f.End {
+			amount = 1
+		}
+	case FogExponential:
+		amount = 1 - math.Exp(-f.Density*distance)
+	case FogExponentialSquared:
+		amount = 1 - math.Exp(-(f.Density*distance)*(f.Density*distance))
+	}
+
+	// The fog is evaluated at the height of the pixel, not along the view ray
+	if f.HeightFalloff > 0 && height > f.Height {
+		amount *= math.Exp(-f.HeightFalloff * (height - f.Height))
+	}
+	return max(0, min(1, amount))
+}
+
+// apply blends a color into the fog
+func (f *Fog) apply(c color.RGBA64, distance, height float64) color.RGBA64 {
+	amount := f.amount(distance, height)
+	if amount <= 0 {
+		return c
+	}
+
+	var fr, fg, fb, fa uint32
+	if f.Color != nil {
+		fr, fg, fb, fa = f.Color.RGBA()
+	} else {
+		fa = 0xFFFF
+	}
+	channel := func(x uint16, y uint32) uint16 {
+		return uint16(float64(x) + amount*(float64(y)-float64(x)))
+	}
+	return color.RGBA64{R: channel(c.R, fr), G: channel(c.G, fg), B: channel(c.B, fb), A: channel(c.A, fa)}
+}
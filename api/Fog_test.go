@@ -0,0 +1,71 @@
+package api
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestFog_Amount(t *testing.T) {
+	tests := []struct {
+		fog                        Fog
+		distance, height, expected float64
+	}{
+		{Fog{Mode: FogLinear, Start: 10, End: 20}, 5, 0, 0},
+		{Fog{Mode: FogLinear, Start: 10, End: 20}, 15, 0, 0.5},
+		{Fog{Mode: FogLinear, Start: 10, End: 20}, 30, 0, 1},
+		{Fog{Mode: FogExponential, Density: 0.1}, 10, 0, 1 - math.Exp(-1)},
+		{Fog{Mode: FogExponentialSquared, Density: 0.1}, 20, 0, 1 - math.Exp(-4)},
+		{Fog{Mode: FogLinear, Start: 0, End: 10, Height: 2, HeightFalloff: 1}, 10, 1, 1},
+		{Fog{Mode: FogLinear, Start: 0, End: 10, Height: 2, HeightFalloff: 1}, 10, 3, math.Exp(-1)},
+	}
+	for i, test := range tests {
+		if amount := test.fog.amount(test.distance, test.height); math.Abs(amount-test.expected) > 1e-9 {
+			t.Fatalf("test %d: expected %v, got %v", i, test.expected, amount)
+		}
+	}
+}
+
+func TestFog_Render(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	texture, err := NewImageTexture(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sky := color.RGBA{R: 100, G: 150, B: 255, A: 255}
+
+	for _, textured := range []bool{false, true} {
+		for _, fog := range []*Fog{nil, {Mode: FogLinear, Color: sky, Start: 0, End: 0.5}} {
+			pixels, fogged := 0, 0
+			engine := NewEngine(32, 32, 90, func(x, y int, c color.Color, userData UserData) {
+				pixels++
+				if color.RGBAModel.Convert(c) == sky {
+					fogged++
+				}
+			}, nil)
+
+			floor := Plane(10, 10, 2, 2)
+			for i := range floor.triangles {
+				if textured {
+					floor.triangles[i].Texture = texture
+				} else {
+					floor.triangles[i].Color = color.RGBA{G: 255, A: 255}
+				}
+			}
+			floor.SetMeshPositionRelative(0, -1, 5)
+			engine.AddMesh(floor)
+			engine.SetFog(fog)
+			engine.Render(nil)
+
+			if pixels == 0 {
+				t.Fatalf("floor is not visible")
+			}
+			if fog == nil && fogged != 0 || fog != nil && fogged != pixels {
+				t.Fatalf("textured %v, fog %v: %d of %d pixels fogged", textured, fog != nil, fogged, pixels)
+			}
+		}
+	}
+}
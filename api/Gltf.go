@@ -0,0 +1,848 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Binary glTF container constants
+const (
+	glbMagic         = 0x46546C67 // "glTF"
+	glbVersion       = 2
+	glbChunkTypeJson = 0x4E4F534A // "JSON"
+	glbChunkTypeBin  = 0x004E4942 // "BIN\0"
+)
+
+// glTF accessor component types
+const (
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+)
+
+// glTF primitive modes. Points and lines can't be rendered and are skipped
+const (
+	gltfModeTriangles     = 4
+	gltfModeTriangleStrip = 5
+	gltfModeTriangleFan   = 6
+)
+
+// The following types mirror the parts of the glTF 2.0 schema that are
+// supported by the loader
+
+type gltfDocument struct {
+	Scene       *int             `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Textures    []gltfTexture    `json:"textures"`
+	Images      []gltfImage      `json:"images"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Mesh        *int      `json:"mesh"`
+	Children    []int     `json:"children"`
+	Matrix      []float64 `json:"matrix"`
+	Translation []float64 `json:"translation"`
+	Rotation    []float64 `json:"rotation"`
+	Scale       []float64 `json:"scale"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int            `json:"bufferView"`
+	ByteOffset    int             `json:"byteOffset"`
+	ComponentType int             `json:"componentType"`
+	Normalized    bool            `json:"normalized"`
+	Count         int             `json:"count"`
+	Type          string          `json:"type"`
+	Sparse        json.RawMessage `json:"sparse"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type gltfBuffer struct {
+	Uri        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type gltfMaterial struct {
+	PbrMetallicRoughness *struct {
+		BaseColorFactor  []float64 `json:"baseColorFactor"`
+		BaseColorTexture *struct {
+			Index    int `json:"index"`
+			TexCoord int `json:"texCoord"`
+		} `json:"baseColorTexture"`
+	} `json:"pbrMetallicRoughness"`
+}
+
+type gltfTexture struct {
+	Source *int `json:"source"`
+}
+
+type gltfImage struct {
+	Uri        string `json:"uri"`
+	MimeType   string `json:"mimeType"`
+	BufferView *int   `json:"bufferView"`
+}
+
+// gltfLoader holds the state required while converting a glTF document
+// into a mesh
+type gltfLoader struct {
+	doc gltfDocument
+
+	// Directory of the loaded file, external resources are relative to it
+	dir string
+
+	// Binary chunk of a .glb file, referenced by a buffer without uri
+	bin []byte
+
+	// Resolved buffer contents
+	buffers [][]byte
+
+	// Textures with the base color factor applied, by material index
+	textures map[int]TextureAtlas
+
+	result *Mesh
+}
+
+// LoadGltf implements glTF 2.0 support for `.gltf` files (with external or data uri
+// buffers) and binary `.glb` files. All primitives of the default scene are merged
+// into a single mesh with the node transformations applied.
+// glTF uses a right-handed coordinate system, positions and normals are converted
+// into the left-handed system of the engine by mirroring the Z axis.
+// Texture coordinates in glTF have their origin in the upper left corner and should
+// be used with `YOriginUpperLeft`
+func LoadGltf(filename string) (*Mesh, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := &gltfLoader{
+		dir:      filepath.Dir(filename),
+		textures: map[int]TextureAtlas{},
+		result:   NewMesh(),
+	}
+
+	jsonData := data
+	if len(data) >= 4 && binary.LittleEndian.Uint32(data) == glbMagic {
+		jsonData, loader.bin, err = parseGlb(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(jsonData, &loader.doc); err != nil {
+		return nil, fmt.Errorf("invalid gltf document: %v", err)
+	}
+
+	if err := loader.loadBuffers(); err != nil {
+		return nil, err
+	}
+
+	if err := loader.loadScene(); err != nil {
+		return nil, err
+	}
+
+	return loader.result, nil
+}
+
+// parseGlb splits a binary glTF container into its JSON and binary chunks
+func parseGlb(data []byte) ([]byte, []byte, error) {
+	if len(data) < 20 {
+		return nil, nil, fmt.Errorf("invalid glb file: header too short")
+	}
+
+	version := binary.LittleEndian.Uint32(data[4:])
+	if version != glbVersion {
+		return nil, nil, fmt.Errorf("unsupported glb version %d", version)
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[8:]))
+	if length > len(data) {
+		return nil, nil, fmt.Errorf("invalid glb file: length %d exceeds file size %d", length, len(data))
+	}
+
+	var jsonChunk, binChunk []byte
+	offset := 12
+	for offset+8 <= length {
+		chunkLength := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		offset += 8
+
+		if chunkLength < 0 || offset+chunkLength > length {
+			return nil, nil, fmt.Errorf("invalid glb file: chunk exceeds file size")
+		}
+
+		switch chunkType {
+		case glbChunkTypeJson:
+			jsonChunk = data[offset : offset+chunkLength]
+		case glbChunkTypeBin:
+			if binChunk == nil {
+				binChunk = data[offset : offset+chunkLength]
+			}
+		}
+
+		offset += chunkLength
+	}
+
+	if jsonChunk == nil {
+		return nil, nil, fmt.Errorf("invalid glb file: missing JSON chunk")
+	}
+
+	return jsonChunk, binChunk, nil
+}
+
+// loadResource resolves a uri either as a data uri or as a path relative to the
+// loaded file
+func (l *gltfLoader) loadResource(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.Index(uri, ",")
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, fmt.Errorf("unsupported data uri: only base64 encoding is supported")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+
+	path, err := url.PathUnescape(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uri '%s': %v", uri, err)
+	}
+	return os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(path)))
+}
+
+func (l *gltfLoader) loadBuffers() error {
+	l.buffers = make([][]byte, len(l.doc.Buffers))
+	for i, buffer := range l.doc.Buffers {
+		var data []byte
+		if buffer.Uri == "" {
+			if l.bin == nil {
+				return fmt.Errorf("buffer %d has no uri and there is no binary chunk", i)
+			}
+			data = l.bin
+		} else {
+			var err error
+			data, err = l.loadResource(buffer.Uri)
+			if err != nil {
+				return fmt.Errorf("error loading buffer %d: %v", i, err)
+			}
+		}
+
+		if len(data) < buffer.ByteLength {
+			return fmt.Errorf("buffer %d is shorter than its declared length %d", i, buffer.ByteLength)
+		}
+		l.buffers[i] = data
+	}
+	return nil
+}
+
+// bufferView returns the bytes referenced by a buffer view
+func (l *gltfLoader) bufferView(index int) ([]byte, int, error) {
+	if index < 0 || index >= len(l.doc.BufferViews) {
+		return nil, 0, fmt.Errorf("invalid buffer view %d", index)
+	}
+	view := l.doc.BufferViews[index]
+	if view.Buffer < 0 || view.Buffer >= len(l.buffers) {
+		return nil, 0, fmt.Errorf("buffer view %d references invalid buffer %d", index, view.Buffer)
+	}
+	buffer := l.buffers[view.Buffer]
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 {
+		return nil, 0, fmt.Errorf("buffer view %d has a negative offset, length or stride", index)
+	}
+	if view.ByteOffset > len(buffer) || view.ByteLength > len(buffer)-view.ByteOffset {
+		return nil, 0, fmt.Errorf("buffer view %d exceeds buffer %d", index, view.Buffer)
+	}
+	return buffer[view.ByteOffset : view.ByteOffset+view.ByteLength], view.ByteStride, nil
+}
+
+// readAccessor returns the elements of an accessor as a flat list of floats together
+// with the number of components per element. Normalized integers are converted into
+// the range 0..1 (or -1..1 for signed types)
+func (l *gltfLoader) readAccessor(index int) ([]float64, int, error) {
+	if index < 0 || index >= len(l.doc.Accessors) {
+		return nil, 0, fmt.Errorf("invalid accessor %d", index)
+	}
+	accessor := l.doc.Accessors[index]
+
+	if len(accessor.Sparse) > 0 {
+		return nil, 0, fmt.Errorf("accessor %d: sparse accessors are not supported", index)
+	}
+	if accessor.ByteOffset < 0 || accessor.Count < 0 {
+		return nil, 0, fmt.Errorf("accessor %d has a negative offset or count", index)
+	}
+
+	components := 0
+	switch accessor.Type {
+	case "SCALAR":
+		components = 1
+	case "VEC2":
+		components = 2
+	case "VEC3":
+		components = 3
+	case "VEC4":
+		components = 4
+	default:
+		return nil, 0, fmt.Errorf("accessor %d: unsupported type '%s'", index, accessor.Type)
+	}
+
+	componentSize := 0
+	switch accessor.ComponentType {
+	case gltfByte, gltfUnsignedByte:
+		componentSize = 1
+	case gltfShort, gltfUnsignedShort:
+		componentSize = 2
+	case gltfUnsignedInt, gltfFloat:
+		componentSize = 4
+	default:
+		return nil, 0, fmt.Errorf("accessor %d: unsupported component type %d", index, accessor.ComponentType)
+	}
+
+	if accessor.Count > math.MaxInt32/components {
+		return nil, 0, fmt.Errorf("accessor %d has too many elements", index)
+	}
+
+	// Accessors without buffer view are initialized with zeros
+	if accessor.BufferView == nil {
+		return make([]float64, accessor.Count*components), components, nil
+	}
+
+	data, stride, err := l.bufferView(*accessor.BufferView)
+	if err != nil {
+		return nil, 0, fmt.Errorf("accessor %d: %v", index, err)
+	}
+	if stride == 0 {
+		stride = componentSize * components
+	}
+
+	// Compared by division, so huge counts can not overflow
+	if accessor.Count > 0 {
+		available := len(data) - accessor.ByteOffset - componentSize*components
+		if available < 0 || accessor.Count-1 > available/stride {
+			return nil, 0, fmt.Errorf("accessor %d exceeds its buffer view", index)
+		}
+	}
+
+	result := make([]float64, accessor.Count*components)
+	for i := 0; i < accessor.Count; i++ {
+		for c := 0; c < components; c++ {
+			offset := accessor.ByteOffset + i*stride + c*componentSize
+			value := 0.0
+			switch accessor.ComponentType {
+			case gltfByte:
+				value = float64(int8(data[offset]))
+				if accessor.Normalized {
+					value = math.Max(value/127, -1)
+				}
+			case gltfUnsignedByte:
+				value = float64(data[offset])
+				if accessor.Normalized {
+					value /= 255
+				}
+			case gltfShort:
+				value = float64(int16(binary.LittleEndian.Uint16(data[offset:])))
+				if accessor.Normalized {
+					value = math.Max(value/32767, -1)
+				}
+			case gltfUnsignedShort:
+				value = float64(binary.LittleEndian.Uint16(data[offset:]))
+				if accessor.Normalized {
+					value /= 65535
+				}
+			case gltfUnsignedInt:
+				value = float64(binary.LittleEndian.Uint32(data[offset:]))
+			case gltfFloat:
+				value = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset:])))
+			}
+			result[i*components+c] = value
+		}
+	}
+
+	return result, components, nil
+}
+
+// loadScene walks the node hierarchy of the default scene. Documents without
+// scenes are loaded from their root nodes, documents without nodes from their meshes
+func (l *gltfLoader) loadScene() error {
+	identity := Identity4x4()
+
+	var roots []int
+	if len(l.doc.Scenes) > 0 {
+		scene := 0
+		if l.doc.Scene != nil {
+			scene = *l.doc.Scene
+		}
+		if scene < 0 || scene >= len(l.doc.Scenes) {
+			return fmt.Errorf("invalid scene %d", scene)
+		}
+		roots = l.doc.Scenes[scene].Nodes
+	} else if len(l.doc.Nodes) > 0 {
+		isChild := make([]bool, len(l.doc.Nodes))
+		for _, node := range l.doc.Nodes {
+			for _, child := range node.Children {
+				if child >= 0 && child < len(isChild) {
+					isChild[child] = true
+				}
+			}
+		}
+		for i := range l.doc.Nodes {
+			if !isChild[i] {
+				roots = append(roots, i)
+			}
+		}
+	} else {
+		for i := range l.doc.Meshes {
+			if err := l.loadMesh(i, &identity); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	visited := make([]bool, len(l.doc.Nodes))
+	for _, root := range roots {
+		if err := l.loadNode(root, &identity, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *gltfLoader) loadNode(index int, parent *Matrix4x4, visited []bool) error {
+	if index < 0 || index >= len(l.doc.Nodes) {
+		return fmt.Errorf("invalid node %d", index)
+	}
+	if visited[index] {
+		return fmt.Errorf("node %d is part of a cycle or has multiple parents", index)
+	}
+	visited[index] = true
+
+	node := l.doc.Nodes[index]
+	local, err := gltfNodeMatrix(&node)
+	if err != nil {
+		return fmt.Errorf("node %d: %v", index, err)
+	}
+
+	// Row vector convention: the local transformation is applied first
+	world := local.MulM(parent)
+
+	if node.Mesh != nil {
+		if err := l.loadMesh(*node.Mesh, &world); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := l.loadNode(child, &world, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gltfNodeMatrix returns the local transformation of a node, either from its matrix
+// or from its translation, rotation and scale properties
+func gltfNodeMatrix(node *gltfNode) (Matrix4x4, error) {
+	if node.Matrix != nil {
+		if len(node.Matrix) != 16 {
+			return Matrix4x4{}, fmt.Errorf("matrix must have 16 elements")
+		}
+		// glTF matrices are column major and use column vectors. The engine uses row
+		// vectors, so the transposed matrix is just the array read row by row
+		matrix := Matrix4x4{}
+		for r := 0; r < 4; r++ {
+			for c := 0; c < 4; c++ {
+				matrix[r][c] = node.Matrix[r*4+c]
+			}
+		}
+		return matrix, nil
+	}
+
+	scale := Identity4x4()
+	if node.Scale != nil {
+		if len(node.Scale) != 3 {
+			return Matrix4x4{}, fmt.Errorf("scale must have 3 elements")
+		}
+		scale[0][0] = node.Scale[0]
+		scale[1][1] = node.Scale[1]
+		scale[2][2] = node.Scale[2]
+	}
+
+	rotation := Identity4x4()
+	if node.Rotation != nil {
+		if len(node.Rotation) != 4 {
+			return Matrix4x4{}, fmt.Errorf("rotation must have 4 elements")
+		}
+		x, y, z, w := node.Rotation[0], node.Rotation[1], node.Rotation[2], node.Rotation[3]
+		rotation[0][0] = 1 - 2*(y*y+z*z)
+		rotation[0][1] = 2 * (x*y + z*w)
+		rotation[0][2] = 2 * (x*z - y*w)
+		rotation[1][0] = 2 * (x*y - z*w)
+		rotation[1][1] = 1 - 2*(x*x+z*z)
+		rotation[1][2] = 2 * (y*z + x*w)
+		rotation[2][0] = 2 * (x*z + y*w)
+		rotation[2][1] = 2 * (y*z - x*w)
+		rotation[2][2] = 1 - 2*(x*x+y*y)
+	}
+
+	translation := Identity4x4()
+	if node.Translation != nil {
+		if len(node.Translation) != 3 {
+			return Matrix4x4{}, fmt.Errorf("translation must have 3 elements")
+		}
+		translation.Translate(node.Translation[0], node.Translation[1], node.Translation[2])
+	}
+
+	matrix := scale.MulM(&rotation)
+	return matrix.MulM(&translation), nil
+}
+
+// gltfNormalMatrix returns the inverse transpose of the upper 3x3 part of the matrix,
+// required to transform normals under non-uniform scaling
+func gltfNormalMatrix(m *Matrix4x4) Matrix4x4 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	result := Matrix4x4{}
+	if math.Abs(det) < 1e-12 {
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				result[r][c] = m[r][c]
+			}
+		}
+		return result
+	}
+
+	// The transposed inverse equals the cofactor matrix divided by the determinant
+	result[0][0] = (m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det
+	result[0][1] = -(m[1][0]*m[2][2] - m[1][2]*m[2][0]) / det
+	result[0][2] = (m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det
+	result[1][0] = -(m[0][1]*m[2][2] - m[0][2]*m[2][1]) / det
+	result[1][1] = (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det
+	result[1][2] = -(m[0][0]*m[2][1] - m[0][1]*m[2][0]) / det
+	result[2][0] = (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det
+	result[2][1] = -(m[0][0]*m[1][2] - m[0][2]*m[1][0]) / det
+	result[2][2] = (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det
+	return result
+}
+
+func (l *gltfLoader) loadMesh(index int, world *Matrix4x4) error {
+	if index < 0 || index >= len(l.doc.Meshes) {
+		return fmt.Errorf("invalid mesh %d", index)
+	}
+	for p, primitive := range l.doc.Meshes[index].Primitives {
+		if err := l.loadPrimitive(&primitive, world); err != nil {
+			return fmt.Errorf("mesh %d, primitive %d: %v", index, p, err)
+		}
+	}
+	return nil
+}
+
+func (l *gltfLoader) loadPrimitive(primitive *gltfPrimitive, world *Matrix4x4) error {
+	mode := gltfModeTriangles
+	if primitive.Mode != nil {
+		mode = *primitive.Mode
+	}
+	if mode != gltfModeTriangles && mode != gltfModeTriangleStrip && mode != gltfModeTriangleFan {
+		// Points and lines
+		return nil
+	}
+
+	positionAccessor, ok := primitive.Attributes["POSITION"]
+	if !ok {
+		return fmt.Errorf("missing POSITION attribute")
+	}
+	positions, components, err := l.readAccessor(positionAccessor)
+	if err != nil {
+		return err
+	}
+	if components != 3 {
+		return fmt.Errorf("POSITION must be VEC3")
+	}
+	vertexCount := len(positions) / 3
+
+	var normals []float64
+	if accessor, ok := primitive.Attributes["NORMAL"]; ok {
+		normals, components, err = l.readAccessor(accessor)
+		if err != nil {
+			return err
+		}
+		if components != 3 || len(normals) != len(positions) {
+			return fmt.Errorf("NORMAL must be VEC3 with one element per vertex")
+		}
+	}
+
+	var colors []float64
+	colorComponents := 0
+	if accessor, ok := primitive.Attributes["COLOR_0"]; ok {
+		colors, colorComponents, err = l.readAccessor(accessor)
+		if err != nil {
+			return err
+		}
+		if (colorComponents != 3 && colorComponents != 4) || len(colors) != vertexCount*colorComponents {
+			return fmt.Errorf("COLOR_0 must be VEC3 or VEC4 with one element per vertex")
+		}
+	}
+
+	// Material
+	factor := [4]float64{1, 1, 1, 1}
+	var texture TextureAtlas
+	texCoord := 0
+	if primitive.Material != nil {
+		materialIndex := *primitive.Material
+		if materialIndex < 0 || materialIndex >= len(l.doc.Materials) {
+			return fmt.Errorf("invalid material %d", materialIndex)
+		}
+		if pbr := l.doc.Materials[materialIndex].PbrMetallicRoughness; pbr != nil {
+			if pbr.BaseColorFactor != nil {
+				if len(pbr.BaseColorFactor) != 4 {
+					return fmt.Errorf("baseColorFactor must have 4 elements")
+				}
+				copy(factor[:], pbr.BaseColorFactor)
+			}
+			if pbr.BaseColorTexture != nil {
+				texCoord = pbr.BaseColorTexture.TexCoord
+				texture, err = l.loadTexture(materialIndex, pbr.BaseColorTexture.Index, factor)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	var uvs []float64
+	if texture != nil {
+		if accessor, ok := primitive.Attributes[fmt.Sprintf("TEXCOORD_%d", texCoord)]; ok {
+			uvs, components, err = l.readAccessor(accessor)
+			if err != nil {
+				return err
+			}
+			if components != 2 || len(uvs) != vertexCount*2 {
+				return fmt.Errorf("TEXCOORD_%d must be VEC2 with one element per vertex", texCoord)
+			}
+		} else {
+			// A texture without coordinates can't be mapped
+			texture = nil
+		}
+	}
+
+	var indices []int
+	if primitive.Indices != nil {
+		values, components, err := l.readAccessor(*primitive.Indices)
+		if err != nil {
+			return err
+		}
+		if components != 1 {
+			return fmt.Errorf("indices must be SCALAR")
+		}
+		indices = make([]int, len(values))
+		for i, value := range values {
+			indices[i] = int(value)
+			if indices[i] < 0 || indices[i] >= vertexCount {
+				return fmt.Errorf("index %d out of range", indices[i])
+			}
+		}
+	} else {
+		indices = make([]int, vertexCount)
+		for i := range indices {
+			indices[i] = i
+		}
+	}
+
+	normalMatrix := gltfNormalMatrix(world)
+
+	vertexAt := func(i int) Vector3d {
+		position := Vector3d{X: positions[i*3], Y: positions[i*3+1], Z: positions[i*3+2], W: 1}
+		position = world.MulV(&position)
+		position.Z = -position.Z
+		position.W = 1
+		return position
+	}
+
+	normalAt := func(i int) Vector3d {
+		if normals == nil {
+			return Vector3d{}
+		}
+		normal := Vector3d{X: normals[i*3], Y: normals[i*3+1], Z: normals[i*3+2]}
+		normal = normalMatrix.MulV(&normal)
+		normal.Z = -normal.Z
+		normal.W = 0
+		if normal.Len() > 0 {
+			normal.Normalize()
+		}
+		return normal
+	}
+
+	addTriangle := func(a, b, c int) {
+		// Mirroring the Z axis flips the winding order, so the last two vertices
+		// are swapped to keep the triangle facing outwards
+		order := [3]int{a, c, b}
+		triangle := Triangle{}
+		for n, i := range order {
+			triangle.Vertices[n] = vertexAt(i)
+			triangle.Normals[n] = normalAt(i)
+			if texture != nil {
+				triangle.UVs[n] = VectorUv{U: uvs[i*2], V: uvs[i*2+1], W: 1}
+			}
+		}
+
+		if texture != nil {
+			triangle.Texture = texture
+		} else {
+			// Vertex colors are kept per vertex, the face color is their average
+			rgba := factor
+			if colors != nil {
+				avg := [4]float64{0, 0, 0, 0}
+				for n, i := range order {
+					vertex := [4]float64{1, 1, 1, 1}
+					for k := 0; k < colorComponents; k++ {
+						vertex[k] = colors[i*colorComponents+k]
+					}
+					for k := range vertex {
+						avg[k] += vertex[k] / 3
+						vertex[k] *= factor[k]
+					}
+					triangle.VertexColors[n] = gltfColor(vertex)
+				}
+				for k := range rgba {
+					rgba[k] *= avg[k]
+				}
+			}
+			triangle.Color = gltfColor(rgba)
+		}
+
+		l.result.AddTriangle(triangle)
+	}
+
+	switch mode {
+	case gltfModeTriangles:
+		for i := 0; i+2 < len(indices); i += 3 {
+			addTriangle(indices[i], indices[i+1], indices[i+2])
+		}
+	case gltfModeTriangleStrip:
+		for i := 0; i+2 < len(indices); i++ {
+			// Every second triangle of a strip has reversed winding
+			if i%2 == 0 {
+				addTriangle(indices[i], indices[i+1], indices[i+2])
+			} else {
+				addTriangle(indices[i+1], indices[i], indices[i+2])
+			}
+		}
+	case gltfModeTriangleFan:
+		for i := 1; i+1 < len(indices); i++ {
+			addTriangle(indices[0], indices[i], indices[i+1])
+		}
+	}
+
+	return nil
+}
+
+// loadTexture decodes the image of a texture and applies the base color factor
+// of the material
+func (l *gltfLoader) loadTexture(materialIndex, textureIndex int, factor [4]float64) (TextureAtlas, error) {
+	if texture, ok := l.textures[materialIndex]; ok {
+		return texture, nil
+	}
+
+	if textureIndex < 0 || textureIndex >= len(l.doc.Textures) {
+		return nil, fmt.Errorf("invalid texture %d", textureIndex)
+	}
+	source := l.doc.Textures[textureIndex].Source
+	if source == nil || *source < 0 || *source >= len(l.doc.Images) {
+		return nil, fmt.Errorf("texture %d has no valid image source", textureIndex)
+	}
+	imageInfo := l.doc.Images[*source]
+
+	var data []byte
+	var err error
+	if imageInfo.BufferView != nil {
+		data, _, err = l.bufferView(*imageInfo.BufferView)
+	} else {
+		data, err = l.loadResource(imageInfo.Uri)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading image %d: %v", *source, err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image %d: %v", *source, err)
+	}
+
+	if factor != [4]float64{1, 1, 1, 1} {
+		img = gltfTintImage(img, factor)
+	}
+
+	texture, err := NewImageTexture(img)
+	if err != nil {
+		return nil, fmt.Errorf("error loading image %d: %v", *source, err)
+	}
+	l.textures[materialIndex] = texture
+	return texture, nil
+}
+
+// gltfTintImage multiplies every pixel of the image with the given factor
+func gltfTintImage(img image.Image, factor [4]float64) image.Image {
+	bounds := img.Bounds()
+	tinted := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			tinted.SetNRGBA(x-bounds.Min.X, y-bounds.Min.Y, gltfColor([4]float64{
+				float64(c.R) / 255 * factor[0],
+				float64(c.G) / 255 * factor[1],
+				float64(c.B) / 255 * factor[2],
+				float64(c.A) / 255 * factor[3],
+			}))
+		}
+	}
+	return tinted
+}
+
+// gltfColor converts linear 0..1 color components into a color
+func gltfColor(rgba [4]float64) color.NRGBA {
+	channel := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	return color.NRGBA{R: channel(rgba[0]), G: channel(rgba[1]), B: channel(rgba[2]), A: channel(rgba[3])}
+}
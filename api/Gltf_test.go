@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gltfTestBuffer returns a buffer with three positions followed by three
+// unsigned short indices
+func gltfTestBuffer() []byte {
+	buffer := &bytes.Buffer{}
+	for _, f := range []float32{0, 0, 0, 1, 0, 0, 0, 1, 0} {
+		_ = binary.Write(buffer, binary.LittleEndian, f)
+	}
+	for _, i := range []uint16{0, 1, 2} {
+		_ = binary.Write(buffer, binary.LittleEndian, i)
+	}
+	// Pad to a multiple of four
+	buffer.Write([]byte{0, 0})
+	return buffer.Bytes()
+}
+
+func gltfTestDocument(bufferUri string, bufferLength int) string {
+	return fmt.Sprintf(`{
+		"asset": {"version": "2.0"},
+		"scene": 0,
+		"scenes": [{"nodes": [0]}],
+		"nodes": [{"children": [1], "translation": [0, 0, 5]}, {"mesh": 0}],
+		"meshes": [{"primitives": [{"attributes": {"POSITION": 0}, "indices": 1, "material": 0}]}],
+		"materials": [{"pbrMetallicRoughness": {"baseColorFactor": [1, 0, 0, 1]}}],
+		"accessors": [
+			{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"},
+			{"bufferView": 1, "componentType": 5123, "count": 3, "type": "SCALAR"}
+		],
+		"bufferViews": [
+			{"buffer": 0, "byteOffset": 0, "byteLength": 36},
+			{"buffer": 0, "byteOffset": 36, "byteLength": 6}
+		],
+		"buffers": [{%s"byteLength": %d}]
+	}`, bufferUri, bufferLength)
+}
+
+func checkGltfTriangle(t *testing.T, mesh *Mesh) {
+	if len(mesh.triangles) != 1 {
+		t.Fatalf("expected 1 triangle, got %d", len(mesh.triangles))
+	}
+
+	// Z is mirrored and the winding order reversed
+	expected := [3]Vector3d{
+		{X: 0, Y: 0, Z: -5, W: 1},
+		{X: 0, Y: 1, Z: -5, W: 1},
+		{X: 1, Y: 0, Z: -5, W: 1},
+	}
+	for i, v := range mesh.triangles[0].Vertices {
+		if math.Abs(v.X-expected[i].X) > 1e-9 || math.Abs(v.Y-expected[i].Y) > 1e-9 || math.Abs(v.Z-expected[i].Z) > 1e-9 {
+			t.Fatalf("vertex %d: expected %v, got %v", i, expected[i], v)
+		}
+	}
+
+	if mesh.triangles[0].Color != (color.NRGBA{R: 255, A: 255}) {
+		t.Fatalf("expected base color factor, got %v", mesh.triangles[0].Color)
+	}
+}
+
+func TestLoadGltf(t *testing.T) {
+	buffer := gltfTestBuffer()
+	uri := fmt.Sprintf(`"uri": "data:application/octet-stream;base64,%s", `, base64.StdEncoding.EncodeToString(buffer))
+
+	filename := filepath.Join(t.TempDir(), "triangle.gltf")
+	if err := os.WriteFile(filename, []byte(gltfTestDocument(uri, len(buffer))), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mesh, err := LoadGltf(filename)
+	if err != nil {
+		t.Fatalf("LoadGltf: %v", err)
+	}
+	checkGltfTriangle(t, mesh)
+}
+
+func TestLoadGltf_Glb(t *testing.T) {
+	buffer := gltfTestBuffer()
+	document := []byte(gltfTestDocument("", len(buffer)))
+	for len(document)%4 != 0 {
+		document = append(document, ' ')
+	}
+
+	glb := &bytes.Buffer{}
+	header := []uint32{glbMagic, glbVersion, uint32(12 + 8 + len(document) + 8 + len(buffer))}
+	_ = binary.Write(glb, binary.LittleEndian, header)
+	_ = binary.Write(glb, binary.LittleEndian, []uint32{uint32(len(document)), glbChunkTypeJson})
+	glb.Write(document)
+	_ = binary.Write(glb, binary.LittleEndian, []uint32{uint32(len(buffer)), glbChunkTypeBin})
+	glb.Write(buffer)
+
+	filename := filepath.Join(t.TempDir(), "triangle.glb")
+	if err := os.WriteFile(filename, glb.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mesh, err := LoadGltf(filename)
+	if err != nil {
+		t.Fatalf("LoadGltf: %v", err)
+	}
+	checkGltfTriangle(t, mesh)
+}
+
+func TestLoadGltf_InvalidOffsets(t *testing.T) {
+	buffer := gltfTestBuffer()
+	uri := fmt.Sprintf(`"uri": "data:application/octet-stream;base64,%s", `, base64.StdEncoding.EncodeToString(buffer))
+	document := gltfTestDocument(uri, len(buffer))
+
+	for _, test := range []struct{ old, new string }{
+		{`"byteOffset": 36`, `"byteOffset": -4`},
+		{`"byteLength": 36`, `"byteLength": -36`},
+		{`"bufferView": 0, `, `"bufferView": 0, "byteOffset": -12, `},
+		{`"count": 3, "type": "VEC3"`, `"count": -3, "type": "VEC3"`},
+		{`"count": 3, "type": "VEC3"`, `"count": 9223372036854775807, "type": "VEC3"`},
+	} {
+		filename := filepath.Join(t.TempDir(), "triangle.gltf")
+		if err := os.WriteFile(filename, []byte(strings.Replace(document, test.old, test.new, 1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadGltf(filename); err == nil {
+			t.Fatalf("%s: expected an error", test.new)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// ImageTexture implements the `TextureAtlas` interface on top of an `image.Image`
+// Coordinates outside the image wrap around, so texture coordinates beyond 0..1
+// repeat the texture
+type ImageTexture struct {
+	img  image.Image
+	w, h int
+}
+
+// NewImageTexture creates a new texture from the given image, which must not be
+// empty
+func NewImageTexture(img image.Image) (*ImageTexture, error) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("empty texture image of size %dx%d", bounds.Dx(), bounds.Dy())
+	}
+	return &ImageTexture{
+		img: img,
+		w:   bounds.Dx(),
+		h:   bounds.Dy(),
+	}, nil
+}
+
+func (t *ImageTexture) W() int {
+	return t.w
+}
+
+func (t *ImageTexture) H() int {
+	return t.h
+}
+
+func (t *ImageTexture) ColorAt(x, y int) color.Color {
+	x %= t.w
+	if x < 0 {
+		x += t.w
+	}
+	y %= t.h
+	if y < 0 {
+		y += t.h
+	}
+	bounds := t.img.Bounds()
+	return t.img.At(bounds.Min.X+x, bounds.Min.Y+y)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageTexture(t *testing.T) {
+	if _, err := NewImageTexture(image.NewRGBA(image.Rect(0, 0, 0, 4))); err == nil {
+		t.Fatal("expected an error for an empty image")
+	}
+
+	img := image.NewRGBA(image.Rect(2, 2, 4, 3))
+	img.Set(3, 2, color.RGBA{R: 255, A: 255})
+	texture, err := NewImageTexture(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Coordinates wrap around in both directions
+	for _, x := range []int{1, 3, -1} {
+		if c := color.RGBAModel.Convert(texture.ColorAt(x, 5)).(color.RGBA); c.R != 255 {
+			t.Fatalf("x %d: expected the red texel, got %v", x, c)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"image/color"
+)
+
+// MaxVaryings is the number of values a vertex shader can pass on to the
+// fragment shader
+const MaxVaryings = 8
+
+// Varyings are values written per vertex by the vertex shader. They are
+// interpolated with perspective correction across the triangle and handed to
+// the fragment shader
+type Varyings [MaxVaryings]float64
+
+// Uniforms hold the values that are the same for all vertices and fragments of a mesh
+type Uniforms struct {
+	World      Matrix4x4
+	View       Matrix4x4
+	Projection Matrix4x4
+
+	// Camera position in world space
+	Camera Vector3d
+}
+
+// VertexInput is a single vertex of a triangle in model space together with its
+// attributes
+type VertexInput struct {
+	Position Vector3d
+	Normal   Vector3d
+	UV       VectorUv
+
+	// Color of the vertex if the triangle has vertex colors, otherwise the color
+	// of the triangle. Nil for textured triangles
+	Color color.Color
+
+	// Index of the vertex in the triangle (0..2) and of the triangle in the mesh
+	Vertex, Triangle int
+
+	Uniforms *Uniforms
+}
+
+// FragmentInput is a single pixel covered by a triangle
+type FragmentInput struct {
+	// Screen position, in samples with anti-aliasing
+	X, Y int
+
+	// Depth as stored in the depth buffer, the interpolated 1/w. Larger values
+	// are closer to the camera
+	Depth float64
+
+	// Perspective corrected texture coordinates and varyings
+	UV       VectorUv
+	Varyings Varyings
+
+	// Color the pixel would have without a fragment shader, the interpolated
+	// vertex color, the triangle color or the texel at UV. Nil if the triangle has
+	// none of them
+	Color color.Color
+
+	// Light is the share of the light of shadow casting lights reaching the
+	// pixel, from 0 in full shadow to 1. Without fragment shader the engine
+	// darkens the color by it, otherwise that is up to the shader
+	Light float64
+
+	Uniforms *Uniforms
+}
+
+// VertexShader transforms a vertex into clip space, which is the position after
+// multiplying with the projection matrix but before the perspective divide, and
+// may write varyings for the fragment shader
+type VertexShader func(in *VertexInput, out *Varyings) Vector3d
+
+// FragmentShader returns the color of a pixel. Returning false discards the pixel,
+// leaving both the target and the depth buffer untouched
+type FragmentShader func(in *FragmentInput) (color.Color, bool)
+
+// Material customizes how the triangles of a mesh are rendered. Both stages are
+// optional, a missing vertex stage uses `DefaultVertexShader` and a missing fragment
+// stage the triangle color or texture
+type Material struct {
+	VertexShader   VertexShader
+	FragmentShader FragmentShader
+}
+
+// DefaultVertexShader applies the world, view and projection matrices, like the
+// pipeline does for meshes without material
+func DefaultVertexShader(in *VertexInput, out *Varyings) Vector3d {
+	position := in.Position
+	position.W = 1
+	position = in.Uniforms.World.MulV(&position)
+	position = in.Uniforms.View.MulV(&position)
+	return in.Uniforms.Projection.MulV(&position)
+}
+
+// shadeMesh runs the vertex stage of the material on all triangles of a mesh and
+// appends them in screen space to `trianglesToRaster`. Triangles are clipped
+// against the near plane in clip space and culled in screen space since the vertex
+// stage may move them arbitrarily
+func (e *Engine) shadeMesh(mesh *Mesh, uniforms *Uniforms) {
+	vertexShader := mesh.material.VertexShader
+	if vertexShader == nil {
+		vertexShader = DefaultVertexShader
+	}
+
+	// Near plane in clip space, z >= 0
+	p := Vector3d{X: 0, Y: 0, Z: 0}
+	n := Vector3d{X: 0, Y: 0, Z: 1}
+
+	// The shader input lives in the engine, handing out pointers to the stack
+	// would move it to the heap for every vertex
+	in := &e.vertexInput
+	varyings := &e.varyings
+	receiveShadows := mesh.receiveShadows && len(e.lights) > 0
+	worldAttributes := receiveShadows || e.fog.heightFog()
+
+	for ti := range mesh.triangles {
+		triangle := &mesh.triangles[ti]
+
+		triangleShaded := Triangle{Color: triangle.Color, Texture: triangle.Texture}
+		triangleShaded.colorAttributes = triangle.hasVertexColors()
+		triangleShaded.index = e.triangleIndex + ti
+		triangleShaded.receiveShadows = receiveShadows
+		for i := range triangleShaded.Vertices {
+			*in = VertexInput{
+				Position: triangle.Vertices[i],
+				Normal:   triangle.Normals[i],
+				UV:       triangle.UVs[i],
+				Color:    triangle.Color,
+				Vertex:   i,
+				Triangle: ti,
+				Uniforms: uniforms,
+			}
+			if triangle.hasVertexColors() {
+				in.Color = triangle.vertexColor(i)
+			}
+			*varyings = Varyings{}
+			triangleShaded.Vertices[i] = vertexShader(in, varyings)
+			triangleShaded.Attributes[i] = vertexAttributes(triangle, i)
+			copy(triangleShaded.Attributes[i][AttributeVaryings:], varyings[:])
+			if worldAttributes {
+				// Shadows and fog ignore vertex shader displacement
+				world := uniforms.World.MulV(&triangle.Vertices[i])
+				setWorldAttributes(&triangleShaded.Attributes[i], &world)
+			}
+		}
+
+		clippedTriangles := [2]Triangle{}
+		numberOfClippedTriangles := triangleShaded.ClipAgainstPlane(&p, &n, &clippedTriangles[0], &clippedTriangles[1])
+		if numberOfClippedTriangles != 1 || clippedTriangles[0].Vertices != triangleShaded.Vertices {
+			e.Metrics.NearClipped++
+		}
+
+		for c := 0; c < numberOfClippedTriangles; c++ {
+			triangleProjected := &clippedTriangles[c]
+			e.projectToScreen(triangleProjected)
+
+			// Visible triangles are wound clockwise on screen
+			v := &triangleProjected.Vertices
+			ax, ay := v[1].X-v[0].X, v[1].Y-v[0].Y
+			bx, by := v[2].X-v[0].X, v[2].Y-v[0].Y
+			if ax*by-bx*ay >= 0 {
+				e.Metrics.BackfaceCulled++
+				if e.debugMode != DebugBackfaces {
+					continue
+				}
+				triangleProjected.backface = true
+			}
+
+			e.trianglesToRaster = append(e.trianglesToRaster, *triangleProjected)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+// renderCoverage renders the mesh and returns the number of pixels drawn
+func renderCoverage(mesh *Mesh) (int, int) {
+	pixels := 0
+	engine := NewEngine(64, 64, 90, func(x, y int, c color.Color, userData UserData) {
+		pixels++
+	}, nil)
+	engine.AddMesh(mesh)
+	engine.SetCameraPositionAbsolute(0.5, 0.5, -2, 0.3, 0.2)
+	engine.Render(nil)
+	return pixels, engine.Metrics.Triangles
+}
+
+func TestMaterial_DefaultStages(t *testing.T) {
+	expectedPixels, expectedTriangles := renderCoverage(ColoredCube())
+
+	mesh := ColoredCube()
+	mesh.SetMaterial(&Material{})
+	pixels, triangles := renderCoverage(mesh)
+
+	if triangles != expectedTriangles {
+		t.Fatalf("expected %d visible triangles, got %d", expectedTriangles, triangles)
+	}
+	if math.Abs(float64(pixels-expectedPixels)) > 0.05*float64(expectedPixels) {
+		t.Fatalf("expected about %d pixels, got %d", expectedPixels, pixels)
+	}
+}
+
+func TestMaterial_Shaders(t *testing.T) {
+	mesh := ColoredCube()
+	fragments := 0
+	mesh.SetMaterial(&Material{
+		VertexShader: func(in *VertexInput, out *Varyings) Vector3d {
+			out[0] = float64(in.Vertex)
+			return DefaultVertexShader(in, out)
+		},
+		FragmentShader: func(in *FragmentInput) (color.Color, bool) {
+			fragments++
+			if in.Varyings[0] < -1e-9 || in.Varyings[0] > 2+1e-9 {
+				t.Fatalf("varying out of range: %v", in.Varyings[0])
+			}
+			return nil, false
+		},
+	})
+
+	pixels, _ := renderCoverage(mesh)
+	if fragments == 0 {
+		t.Fatalf("fragment shader was not called")
+	}
+	if pixels != 0 {
+		t.Fatalf("expected all fragments to be discarded, got %d pixels", pixels)
+	}
+}
@@ -29,6 +29,18 @@ func Projection4x4(fov, aspectRatio, near, far float64) Matrix4x4 {
 	return matrix
 }
 
+// Orthographic4x4 returns a projection matrix without perspective. X and Y from
+// -size to size are mapped to -1..1, Z from near to far to 0..1
+func Orthographic4x4(size, near, far float64) Matrix4x4 {
+	matrix := Matrix4x4{}
+	matrix[0][0] = 1 / size
+	matrix[1][1] = 1 / size
+	matrix[2][2] = 1 / (far - near)
+	matrix[3][2] = -near / (far - near)
+	matrix[3][3] = 1.0
+	return matrix
+}
+
 // MulM multiplies the matrix with another matrix of the same order, returning a new matrix
 func (m *Matrix4x4) MulM(other *Matrix4x4) Matrix4x4 {
 	result := Matrix4x4{}
@@ -17,3 +17,20 @@ func TestIdentity4x4(t *testing.T) {
 		t.Errorf("Identity4x4(): \n%#v\n%#v", m1, m2)
 	}
 }
+
+// matrixSink keeps the compiler from optimizing benchmarked results away
+var matrixSink Vector3d
+
+func BenchmarkMatrix4x4_MulV(b *testing.B) {
+	rotation := Identity4x4()
+	rotation.RotateY(0.3)
+	translation := Identity4x4()
+	translation.Translate(1, 2, 3)
+	m := rotation.MulM(&translation)
+	v := Vector3d{X: 1, Y: 2, Z: 3, W: 1}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matrixSink = m.MulV(&v)
+	}
+}
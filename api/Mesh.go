@@ -3,14 +3,42 @@ package api
 import (
 	"bufio"
 	"fmt"
+	"image/color"
+	"math"
 	"os"
 	"strconv"
 	"strings"
 )
 
+// Point is a single point of a point cloud
+type Point struct {
+	Position Vector3d
+
+	// Optional normal
+	Normal Vector3d
+
+	// Color of the point, white if not set
+	Color color.Color
+}
+
 type Mesh struct {
 	triangles []Triangle
 
+	// Points are rendered as single pixels. Meshes without triangles
+	// are point clouds
+	points []Point
+
+	// Optional material with shader stages
+	material *Material
+
+	// Render textures used by the triangles, engines render them before their
+	// frames
+	renderTextures []*RenderTexture
+
+	// Whether the mesh is rendered into shadow maps and darkened by them
+	castShadows    bool
+	receiveShadows bool
+
 	// Rotation around origin
 	rotX Matrix4x4
 	rotY Matrix4x4
@@ -49,10 +77,25 @@ func NewMesh() *Mesh {
 	mesh.rotYAround = Identity4x4()
 	mesh.rotZAround = Identity4x4()
 	mesh.world = Identity4x4()
+	mesh.castShadows = true
+	mesh.receiveShadows = true
 	mesh.Translate(0, 0, 0)
 	return mesh
 }
 
+// updateWorld recalculates the world matrix
+func (m *Mesh) updateWorld() {
+	// Apply rotations and translations to the world matrix
+	m.world = Identity4x4()
+	m.world = m.world.MulM(&m.trans)
+	m.world = m.world.MulM(&m.rotX)
+	m.world = m.world.MulM(&m.rotY)
+	m.world = m.world.MulM(&m.rotZ)
+	m.world = m.world.MulM(&m.rotXAround)
+	m.world = m.world.MulM(&m.rotYAround)
+	m.world = m.world.MulM(&m.rotZAround)
+}
+
 // updateBoundingBox establishes a bounding box of minimum and maximum coordinates
 // in every direction
 func (m *Mesh) updateBoundingBox(v *Vector3d) {
@@ -79,11 +122,35 @@ func (m *Mesh) updateBoundingBox(v *Vector3d) {
 // AddTriangle adds a single triangle to the mesh
 func (m *Mesh) AddTriangle(triangle Triangle) {
 	m.triangles = append(m.triangles, triangle)
+	m.trackTexture(triangle.Texture)
 	for _, v := range triangle.Vertices {
 		m.updateBoundingBox(&v)
 	}
 }
 
+// SetMaterial sets the material used to render the triangles of the mesh. Pass
+// nil to render them without shaders
+func (m *Mesh) SetMaterial(material *Material) {
+	m.material = material
+}
+
+// SetCastShadows sets whether the mesh casts shadows, which is the default
+func (m *Mesh) SetCastShadows(cast bool) {
+	m.castShadows = cast
+}
+
+// SetReceiveShadows sets whether shadows of other meshes and the mesh itself fall
+// on the mesh, which is the default
+func (m *Mesh) SetReceiveShadows(receive bool) {
+	m.receiveShadows = receive
+}
+
+// AddPoint adds a single point to the mesh
+func (m *Mesh) AddPoint(point Point) {
+	m.points = append(m.points, point)
+	m.updateBoundingBox(&point.Position)
+}
+
 // AddTriangles adds a list of triangles to the mesh
 func (m *Mesh) AddTriangles(triangles []Triangle) {
 	for _, triangle := range triangles {
@@ -140,6 +207,12 @@ func (m *Mesh) MoveRelative(dx, dy, dz float64) {
 			m.updateBoundingBox(&m.triangles[i].Vertices[v])
 		}
 	}
+	for i := range m.points {
+		m.points[i].Position.X += dx
+		m.points[i].Position.Y += dy
+		m.points[i].Position.Z += dz
+		m.updateBoundingBox(&m.points[i].Position)
+	}
 }
 
 // Translate translates the mesh to an absolute position
@@ -213,6 +286,11 @@ func (m *Mesh) Copy() *Mesh {
 	for _, t := range m.triangles {
 		duplicate.triangles = append(duplicate.triangles, t.Copy())
 	}
+	duplicate.points = append(duplicate.points, m.points...)
+	duplicate.material = m.material
+	duplicate.renderTextures = append(duplicate.renderTextures, m.renderTextures...)
+	duplicate.castShadows = m.castShadows
+	duplicate.receiveShadows = m.receiveShadows
 	return duplicate
 }
 
@@ -221,9 +299,16 @@ func (m *Mesh) SetMeshPositionRelative(dx, dy, dz float64) {
 	for i := range m.triangles {
 		m.triangles[i].SetTrianglePositionRelative(dx, dy, dz)
 	}
+	for i := range m.points {
+		m.points[i].Position.X += dx
+		m.points[i].Position.Y += dy
+		m.points[i].Position.Z += dz
+	}
 }
 
-// LoadWavefrontObj implements rudimentary Wavefront obj file format support
+// LoadWavefrontObj implements rudimentary Wavefront obj file format support.
+// Vertex colors of the common `v x y z r g b` extension are read into
+// `VertexColors`
 func LoadWavefrontObj(filename string) (*Mesh, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -238,10 +323,16 @@ func LoadWavefrontObj(filename string) (*Mesh, error) {
 
 	result := NewMesh()
 
-	// Arrays to contain all vertices and uvs found in the file
+	// Arrays to contain all vertices, uvs and normals found in the file
 	// They will later be referenced from face information
 	var vertices []Vector3d
 	var uvs []VectorUv
+	var normals []Vector3d
+
+	// Optional vertex colors of the `v x y z r g b` extension, nil for vertices
+	// without color
+	var colors []color.Color
+	hasColors := false
 
 	parseUV := func(line string, lineNumber int) (*VectorUv, error) {
 		parts := strings.Split(line, " ")
@@ -286,6 +377,39 @@ func LoadWavefrontObj(filename string) (*Mesh, error) {
 		return &Vector3d{x, y, z, 1}, nil
 	}
 
+	// parseColoredVertex parses a vertex with an optional color in the range 0..1
+	// appended to the position
+	parseColoredVertex := func(line string, lineNumber int) (*Vector3d, color.Color, error) {
+		parts := strings.Split(line, " ")
+		if len(parts) != 6 {
+			vertex, err := parseVertex(line, lineNumber)
+			return vertex, nil, err
+		}
+
+		vertex, err := parseVertex(strings.Join(parts[:3], " "), lineNumber)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var channels [3]uint16
+		for i := range channels {
+			c, err := strconv.ParseFloat(parts[3+i], 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid float in v line: '%s' in line %d", line, lineNumber)
+			}
+			channels[i] = uint16(math.Max(0, math.Min(1, c)) * 0xFFFF)
+		}
+
+		return vertex, color.RGBA64{R: channels[0], G: channels[1], B: channels[2], A: 0xFFFF}, nil
+	}
+
+	colorAt := func(index int64) color.Color {
+		if !hasColors {
+			return nil
+		}
+		return colors[index-1]
+	}
+
 	parseFaceNoTexture := func(line string, lineNumber int) ([]Triangle, error) {
 		triangles := []Triangle{}
 		parts := strings.Split(line, " ")
@@ -306,7 +430,8 @@ func LoadWavefrontObj(filename string) (*Mesh, error) {
 			}
 
 			triangles = append(triangles, Triangle{
-				Vertices: [3]Vector3d{vertices[fa-1], vertices[fb-1], vertices[fc-1]},
+				Vertices:     [3]Vector3d{vertices[fa-1], vertices[fb-1], vertices[fc-1]},
+				VertexColors: [3]color.Color{colorAt(fa), colorAt(fb), colorAt(fc)},
 			})
 		} else if len(parts) == 4 {
 			fa, err := strconv.ParseInt(parts[0], 10, 32)
@@ -330,11 +455,13 @@ func LoadWavefrontObj(filename string) (*Mesh, error) {
 			}
 
 			triangles = append(triangles, Triangle{
-				Vertices: [3]Vector3d{vertices[fa-1], vertices[fb-1], vertices[fc-1]},
+				Vertices:     [3]Vector3d{vertices[fa-1], vertices[fb-1], vertices[fc-1]},
+				VertexColors: [3]color.Color{colorAt(fa), colorAt(fb), colorAt(fc)},
 			})
 
 			triangles = append(triangles, Triangle{
-				Vertices: [3]Vector3d{vertices[fa-1], vertices[fc-1], vertices[fd-1]},
+				Vertices:     [3]Vector3d{vertices[fa-1], vertices[fc-1], vertices[fd-1]},
+				VertexColors: [3]color.Color{colorAt(fa), colorAt(fc), colorAt(fd)},
 			})
 		} else {
 			return nil, fmt.Errorf("invalid face line: '%s' in line %d", line, lineNumber)
@@ -343,75 +470,107 @@ func LoadWavefrontObj(filename string) (*Mesh, error) {
 		return triangles, nil
 	}
 
-	parseVertexWithTexture := func(part string, lineNumber int) (int64, int64, error) {
+	// parseVertexWithTexture parses the `v/vt`, `v/vt/vn` and `v//vn` forms of a face
+	// vertex. Missing texture or normal indices are returned as 0
+	parseVertexWithTexture := func(part string, lineNumber int) (int64, int64, int64, error) {
 		parts := strings.Split(part, "/")
 		if len(parts) == 3 || len(parts) == 2 {
 			vertexIndex, err := strconv.ParseInt(parts[0], 10, 32)
 			if err != nil {
-				return 0, 0, err
+				return 0, 0, 0, err
 			}
-			uvIndex, err := strconv.ParseInt(parts[1], 10, 32)
-			if err != nil {
-				return 0, 0, err
+			uvIndex := int64(0)
+			if parts[1] != "" {
+				uvIndex, err = strconv.ParseInt(parts[1], 10, 32)
+				if err != nil {
+					return 0, 0, 0, err
+				}
 			}
-			return vertexIndex, uvIndex, nil
+			normalIndex := int64(0)
+			if len(parts) == 3 {
+				normalIndex, err = strconv.ParseInt(parts[2], 10, 32)
+				if err != nil {
+					return 0, 0, 0, err
+				}
+			}
+			return vertexIndex, uvIndex, normalIndex, nil
 		} else {
-			return 0, 0, fmt.Errorf("invalid face line: '%s' in line %d", part, lineNumber)
+			return 0, 0, 0, fmt.Errorf("invalid face line: '%s' in line %d", part, lineNumber)
+		}
+	}
+
+	uvAt := func(index int64) VectorUv {
+		if index == 0 {
+			return VectorUv{}
 		}
+		return uvs[index-1]
+	}
+
+	normalAt := func(index int64) Vector3d {
+		if index == 0 {
+			return Vector3d{}
+		}
+		return normals[index-1]
 	}
 
 	parseFaceWithTexture := func(line string, lineNumber int) ([]Triangle, error) {
 		var triangles []Triangle
 		parts := strings.Split(line, " ")
 		if len(parts) == 3 {
-			vertexA, uvA, err := parseVertexWithTexture(parts[0], lineNumber)
+			vertexA, uvA, normalA, err := parseVertexWithTexture(parts[0], lineNumber)
 			if err != nil {
 				return nil, err
 			}
 
-			vertexB, uvB, err := parseVertexWithTexture(parts[1], lineNumber)
+			vertexB, uvB, normalB, err := parseVertexWithTexture(parts[1], lineNumber)
 			if err != nil {
 				return nil, err
 			}
 
-			vertexC, uvC, err := parseVertexWithTexture(parts[2], lineNumber)
+			vertexC, uvC, normalC, err := parseVertexWithTexture(parts[2], lineNumber)
 			if err != nil {
 				return nil, err
 			}
 
 			triangles = append(triangles, Triangle{
-				Vertices: [3]Vector3d{vertices[vertexA-1], vertices[vertexB-1], vertices[vertexC-1]},
-				UVs:      [3]VectorUv{uvs[uvA-1], uvs[uvB-1], uvs[uvC-1]},
+				Vertices:     [3]Vector3d{vertices[vertexA-1], vertices[vertexB-1], vertices[vertexC-1]},
+				VertexColors: [3]color.Color{colorAt(vertexA), colorAt(vertexB), colorAt(vertexC)},
+				UVs:          [3]VectorUv{uvAt(uvA), uvAt(uvB), uvAt(uvC)},
+				Normals:      [3]Vector3d{normalAt(normalA), normalAt(normalB), normalAt(normalC)},
 			})
 		} else if len(parts) == 4 {
-			fa, uva, err := parseVertexWithTexture(parts[0], lineNumber)
+			fa, uva, na, err := parseVertexWithTexture(parts[0], lineNumber)
 			if err != nil {
 				return nil, err
 			}
 
-			fb, uvb, err := parseVertexWithTexture(parts[1], lineNumber)
+			fb, uvb, nb, err := parseVertexWithTexture(parts[1], lineNumber)
 			if err != nil {
 				return nil, err
 			}
 
-			fc, uvc, err := parseVertexWithTexture(parts[2], lineNumber)
+			fc, uvc, nc, err := parseVertexWithTexture(parts[2], lineNumber)
 			if err != nil {
 				return nil, err
 			}
 
-			fd, uvd, err := parseVertexWithTexture(parts[3], lineNumber)
+			fd, uvd, nd, err := parseVertexWithTexture(parts[3], lineNumber)
 			if err != nil {
 				return nil, err
 			}
 
 			triangles = append(triangles, Triangle{
-				Vertices: [3]Vector3d{vertices[fa-1], vertices[fb-1], vertices[fc-1]},
-				UVs:      [3]VectorUv{uvs[uva-1], uvs[uvb-1], uvs[uvc-1]},
+				Vertices:     [3]Vector3d{vertices[fa-1], vertices[fb-1], vertices[fc-1]},
+				VertexColors: [3]color.Color{colorAt(fa), colorAt(fb), colorAt(fc)},
+				UVs:          [3]VectorUv{uvAt(uva), uvAt(uvb), uvAt(uvc)},
+				Normals:      [3]Vector3d{normalAt(na), normalAt(nb), normalAt(nc)},
 			})
 
 			triangles = append(triangles, Triangle{
-				Vertices: [3]Vector3d{vertices[fa-1], vertices[fc-1], vertices[fd-1]},
-				UVs:      [3]VectorUv{uvs[uva-1], uvs[uvc-1], uvs[uvd-1]},
+				Vertices:     [3]Vector3d{vertices[fa-1], vertices[fc-1], vertices[fd-1]},
+				VertexColors: [3]color.Color{colorAt(fa), colorAt(fc), colorAt(fd)},
+				UVs:          [3]VectorUv{uvAt(uva), uvAt(uvc), uvAt(uvd)},
+				Normals:      [3]Vector3d{normalAt(na), normalAt(nc), normalAt(nd)},
 			})
 		} else {
 			return nil, fmt.Errorf("invalid face line: '%s' in line %d", line, lineNumber)
@@ -432,8 +591,13 @@ func LoadWavefrontObj(filename string) (*Mesh, error) {
 
 		if currentLine[0] == 'v' {
 			if currentLine[1] == 'n' {
-				// `vn` vertex normal. Not supported.
-				continue
+				// `vn` (vertex normal)
+				normal, err := parseVertex(currentLine[3:], lineNumber)
+				if err != nil {
+					return nil, err
+				}
+				normal.W = 0
+				normals = append(normals, *normal)
 			} else if currentLine[1] == 't' {
 				// `vt` (vertex texture)
 				uv, err := parseUV(currentLine[3:], lineNumber)
@@ -443,11 +607,13 @@ func LoadWavefrontObj(filename string) (*Mesh, error) {
 				uvs = append(uvs, *uv)
 			} else {
 				// `v` (vertex)
-				vertex, err := parseVertex(currentLine[2:], lineNumber)
+				vertex, c, err := parseColoredVertex(currentLine[2:], lineNumber)
 				if err != nil {
 					return nil, err
 				}
 				vertices = append(vertices, *vertex)
+				colors = append(colors, c)
+				hasColors = hasColors || c != nil
 			}
 		} else if currentLine[0] == 'f' {
 			// The texture index can be appended after the vertex index, separated by a slash
@@ -1,10 +1,129 @@
 package api
 
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// DefaultMetricsFrames is the number of frames the rolling frame time statistics
+// cover if `EngineOptions.MetricsFrames` is not set
+const DefaultMetricsFrames = 60
+
 type Metrics struct {
 	// Triangles rendered in the last call to `Render`
 	Triangles int
 
 	// RenderTime represents the time in milliseconds it took to render all meshes during a single
-	// call to `Render`
+	// call to `Render`. Use `FrameTime` for a higher resolution
 	RenderTime int64
+
+	// FrameTime is the time the last call to `Render` took
+	FrameTime time.Duration
+
+	// Time spent in the stages of the last frame. Transform includes vertex
+	// shading, backface culling and clipping against the near plane, clipping
+	// is the clipping against the screen edges
+	TransformTime     time.Duration
+	ClippingTime      time.Duration
+	RasterizationTime time.Duration
+
+	// ShadowTime is the time spent rendering the shadow maps of all lights
+	ShadowTime time.Duration
+
+	// PostProcessingTime is the time spent in post effects
+	PostProcessingTime time.Duration
+
+	// FlushTime is the time spent handing buffered output to the hooks after
+	// rasterization. Pixels are handed out while rasterizing, unless the engine
+	// has to buffer them first like the overdraw and depth debug modes and post
+	// effects do. Otherwise it is zero
+	FlushTime time.Duration
+
+	// Triangles of all meshes before culling
+	InputTriangles int
+
+	// Triangles facing away from the camera
+	BackfaceCulled int
+
+	// Triangles removed, shrunk or split by the near plane
+	NearClipped int
+
+	// Triangles crossing the screen edges and the number of triangles they were
+	// split into
+	ScreenClipped       int
+	ScreenClippedOutput int
+
+	// Pixels that passed the depth test and were written, and pixels hidden
+	// by a closer one
+	PixelsWritten  int
+	PixelsRejected int
+
+	// Ring buffer of the most recent frame times
+	frameTimes []time.Duration
+	nextFrame  int
+	sorted     []time.Duration
+}
+
+// newMetrics creates metrics with statistics over the given number of frames
+func newMetrics(frames int) Metrics {
+	return Metrics{
+		frameTimes: make([]time.Duration, 0, frames),
+		sorted:     make([]time.Duration, 0, frames),
+	}
+}
+
+// beginFrame resets all values of the last frame, the rolling statistics are kept
+func (m *Metrics) beginFrame() {
+	frameTimes, nextFrame, sorted := m.frameTimes, m.nextFrame, m.sorted
+	*m = Metrics{}
+	m.frameTimes, m.nextFrame, m.sorted = frameTimes, nextFrame, sorted
+}
+
+// endFrame records the time of a finished frame
+func (m *Metrics) endFrame(frameTime time.Duration) {
+	m.FrameTime = frameTime
+	m.RenderTime = frameTime.Milliseconds()
+
+	if len(m.frameTimes) < cap(m.frameTimes) {
+		m.frameTimes = append(m.frameTimes, frameTime)
+		return
+	}
+	if len(m.frameTimes) == 0 {
+		return
+	}
+	m.frameTimes[m.nextFrame] = frameTime
+	m.nextFrame = (m.nextFrame + 1) % len(m.frameTimes)
+}
+
+// Frames returns the number of frames the rolling statistics currently cover
+func (m *Metrics) Frames() int {
+	return len(m.frameTimes)
+}
+
+// AverageFrameTime returns the average frame time over the recent frames
+func (m *Metrics) AverageFrameTime() time.Duration {
+	if len(m.frameTimes) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, frameTime := range m.frameTimes {
+		total += frameTime
+	}
+	return total / time.Duration(len(m.frameTimes))
+}
+
+// FrameTimePercentile returns the frame time that the given percentage (0 - 100) of
+// the recent frames did not exceed, e.g. 99 for the 99th percentile
+func (m *Metrics) FrameTimePercentile(percentile float64) time.Duration {
+	if len(m.frameTimes) == 0 {
+		return 0
+	}
+	m.sorted = append(m.sorted[:0], m.frameTimes...)
+	slices.Sort(m.sorted)
+
+	// Nearest rank
+	rank := int(math.Ceil(percentile/100*float64(len(m.sorted)))) - 1
+	rank = max(0, min(len(m.sorted)-1, rank))
+	return m.sorted[rank]
 }
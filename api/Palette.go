@@ -0,0 +1,191 @@
+package api
+
+import (
+	"image/color"
+	"math"
+	"time"
+)
+
+// PaletteHook receives the index of the palette color of a pixel instead of the
+// color itself
+type PaletteHook func(x, y int, index uint8, userData UserData)
+
+// PaletteDither selects how colors between the colors of the palette are
+// approximated
+type PaletteDither int
+
+const (
+	// PaletteDitherNone maps every pixel to the nearest palette color
+	PaletteDitherNone PaletteDither = iota
+
+	// PaletteDitherFloydSteinberg diffuses the error of every pixel to its
+	// neighbours to the right and below
+	PaletteDitherFloydSteinberg
+
+	// PaletteDitherBayer adds an ordered 4x4 pattern before the nearest color is
+	// chosen
+	PaletteDitherBayer
+)
+
+// MaxPaletteColors is the number of palette colors that can be addressed
+const MaxPaletteColors = 256
+
+// paletteSpread returns how far the colors of an evenly distributed palette of the
+// given size lie apart per channel, which is how far ordered dithering spreads
+func paletteSpread(colors int) float64 {
+	return 255 / max(1, math.Cbrt(float64(colors))-1)
+}
+
+// paletteBuffer collects the frame for the palette hook. The frame is quantized
+// once it is complete, so the error of a pixel can be diffused across the frame
+type paletteBuffer struct {
+	colors []color.RGBA
+	dither PaletteDither
+	output PaletteHook
+
+	// The colors as returned by their RGBA method, matched like
+	// `color.Palette.Index` does without converting them for every lookup
+	channels [][4]uint32
+
+	// Frame in the size of the target, replacing the hooks while rendering
+	w, h  int
+	frame []color.RGBA
+	hook  SpanHook
+
+	// Cache of the nearest palette colors, indexed by the color reduced to 5
+	// bits per channel. Every entry remembers the full color it was looked up
+	// for, other colors of the same slot replace it
+	nearest []paletteMatch
+
+	// Error diffused into the current and the next row, padded by a pixel on
+	// both sides
+	errors, nextErrors [][3]float64
+}
+
+// paletteMatch is a cached palette lookup. The color is 0x1rrggbb, so entries
+// that were never written do not match any color
+type paletteMatch struct {
+	color uint32
+	index uint8
+}
+
+// newPaletteBuffer creates the palette stage for a target of the given size.
+// Colors beyond `MaxPaletteColors` are ignored
+func newPaletteBuffer(palette color.Palette, dither PaletteDither, output PaletteHook, w, h int) *paletteBuffer {
+	p := &paletteBuffer{
+		dither:     dither,
+		output:     output,
+		w:          w,
+		h:          h,
+		frame:      make([]color.RGBA, w*h),
+		nearest:    make([]paletteMatch, 1<<15),
+		errors:     make([][3]float64, w+2),
+		nextErrors: make([][3]float64, w+2),
+	}
+	for _, c := range palette[:min(len(palette), MaxPaletteColors)] {
+		p.colors = append(p.colors, toRGBA(c))
+		r, g, b, a := c.RGBA()
+		p.channels = append(p.channels, [4]uint32{r, g, b, a})
+	}
+	p.hook = p.write
+	return p
+}
+
+// write stores a span of the frame
+func (p *paletteBuffer) write(y, x0, x1 int, colors []color.RGBA, userData UserData) {
+	copy(p.frame[y*p.w+x0:y*p.w+x1], colors)
+}
+
+// index returns the palette color closest to a color like `color.Palette.Index`.
+// Colors sharing a cache slot replace each other, so the result is always exact
+func (p *paletteBuffer) index(r, g, b uint8) uint8 {
+	key := 1<<24 | uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+	match := &p.nearest[int(r>>3)<<10|int(g>>3)<<5|int(b>>3)]
+	if match.color != key {
+		match.color = key
+		match.index = p.nearestIndex(r, g, b)
+	}
+	return match.index
+}
+
+// nearestIndex searches the palette color closest to an opaque color with the
+// metric of `color.Palette.Index`, the first of equally close colors wins
+func (p *paletteBuffer) nearestIndex(r, g, b uint8) uint8 {
+	// Scaled to 16 bits like the RGBA method does
+	cr, cg, cb := uint32(r)*0x101, uint32(g)*0x101, uint32(b)*0x101
+	best, bestSum := 0, uint32(math.MaxUint32)
+	for i, c := range p.channels {
+		sum := paletteDiff(cr, c[0]) + paletteDiff(cg, c[1]) + paletteDiff(cb, c[2]) + paletteDiff(0xffff, c[3])
+		if sum < bestSum {
+			if sum == 0 {
+				return uint8(i)
+			}
+			best, bestSum = i, sum
+		}
+	}
+	return uint8(best)
+}
+
+// paletteDiff returns the squared difference of two 16-bit channels divided by
+// 4, so the sum of four fits into 32 bits
+func paletteDiff(x, y uint32) uint32 {
+	d := x - y
+	return (d * d) >> 2
+}
+
+// resolvePalette quantizes the frame and hands the palette indices to the hook.
+// Pixels nothing was drawn to are skipped and take no part in dithering
+func (e *Engine) resolvePalette(userData UserData) {
+	p := e.palette
+	if len(p.colors) == 0 {
+		return
+	}
+	start := time.Now()
+	spread := paletteSpread(len(p.colors))
+	clear(p.nextErrors)
+
+	for y := 0; y < p.h; y++ {
+		p.errors, p.nextErrors = p.nextErrors, p.errors
+		clear(p.nextErrors)
+
+		for x, c := range p.frame[y*p.w : (y+1)*p.w] {
+			if c.A == 0 {
+				continue
+			}
+
+			value := [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+			switch p.dither {
+			case PaletteDitherFloydSteinberg:
+				for k := range value {
+					value[k] += p.errors[x+1][k]
+				}
+			case PaletteDitherBayer:
+				offset := bayerOffset(x, y) * spread
+				for k := range value {
+					value[k] += offset
+				}
+			}
+			channel := func(v float64) uint8 {
+				return uint8(max(0, min(255, math.Round(v))))
+			}
+			index := p.index(channel(value[0]), channel(value[1]), channel(value[2]))
+
+			if p.dither == PaletteDitherFloydSteinberg {
+				matched := p.colors[index]
+				difference := [3]float64{
+					value[0] - float64(matched.R),
+					value[1] - float64(matched.G),
+					value[2] - float64(matched.B),
+				}
+				for k, d := range difference {
+					p.errors[x+2][k] += d * 7 / 16
+					p.nextErrors[x][k] += d * 3 / 16
+					p.nextErrors[x+1][k] += d * 5 / 16
+					p.nextErrors[x+2][k] += d * 1 / 16
+				}
+			}
+			p.output(x, y, index, userData)
+		}
+	}
+	e.Metrics.FlushTime += time.Since(start)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestPalette_Nearest(t *testing.T) {
+	palette := color.Palette{color.Black, color.RGBA{R: 255, A: 255}, color.White}
+	indices := map[[2]int]uint8{}
+	engine := NewEngine(32, 32, 90, nil, &EngineOptions{
+		Palette: palette,
+		PaletteHook: func(x, y int, index uint8, userData UserData) {
+			if _, ok := indices[[2]int{x, y}]; ok {
+				t.Fatalf("pixel %d/%d drawn twice", x, y)
+			}
+			indices[[2]int{x, y}] = index
+		},
+	})
+
+	mesh := Box(1, 1, 1, 1, 1, 1)
+	for i := range mesh.triangles {
+		mesh.triangles[i].Color = color.RGBA{R: 200, G: 30, B: 20, A: 255}
+	}
+	mesh.SetMeshPositionRelative(0, 0, 3)
+	engine.AddMesh(mesh)
+	engine.Render(nil)
+
+	if len(indices) == 0 || len(indices) == 32*32 {
+		t.Fatalf("expected the box to cover part of the target, got %d pixels", len(indices))
+	}
+	for position, index := range indices {
+		if index != 1 {
+			t.Fatalf("pixel %v: expected red, got index %d", position, index)
+		}
+	}
+}
+
+func TestPalette_Dither(t *testing.T) {
+	for _, dither := range []PaletteDither{PaletteDitherNone, PaletteDitherFloydSteinberg, PaletteDitherBayer} {
+		white, pixels := 0, 0
+		engine := NewEngine(32, 32, 90, nil, &EngineOptions{
+			Palette:       color.Palette{color.Black, color.White},
+			PaletteDither: dither,
+			PaletteHook: func(x, y int, index uint8, userData UserData) {
+				pixels++
+				white += int(index)
+			},
+		})
+		engine.SetBackground(&SolidBackground{Color: color.Gray{Y: 100}})
+		engine.Render(nil)
+
+		if pixels != 32*32 {
+			t.Fatalf("dither %d: expected every pixel, got %d", dither, pixels)
+		}
+
+		// Without dithering the gray is closer to black, dithering keeps the
+		// brightness on average
+		expected := 0
+		if dither != PaletteDitherNone {
+			expected = 32 * 32 * 100 / 255
+		}
+		if white < expected-32 || white > expected+32 {
+			t.Fatalf("dither %d: expected about %d white pixels, got %d", dither, expected, white)
+		}
+	}
+}
+
+func TestPalette_Cache(t *testing.T) {
+	// Neighbouring grays fall into the same cache slot but match different
+	// palette colors
+	palette := color.Palette{}
+	for level := 0; level < 256; level += 3 {
+		palette = append(palette, color.Gray{Y: uint8(level)})
+	}
+	palette = append(palette, color.RGBA{R: 201, G: 13, B: 77, A: 255}, color.RGBA{R: 14, G: 250, B: 99, A: 255})
+	buffer := newPaletteBuffer(palette, PaletteDitherNone, nil, 1, 1)
+
+	// The second pass is served from the cache
+	for pass := 0; pass < 2; pass++ {
+		for v := 0; v < 256; v += 5 {
+			for _, c := range []color.RGBA{
+				{R: uint8(v), G: uint8(v), B: uint8(v), A: 255},
+				{R: uint8(v), G: uint8(255 - v), B: uint8(v / 2), A: 255},
+			} {
+				if index, expected := buffer.index(c.R, c.G, c.B), palette.Index(c); int(index) != expected {
+					t.Fatalf("pass %d, color %v: expected index %d, got %d", pass, c, expected, index)
+				}
+			}
+		}
+	}
+}
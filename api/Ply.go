@@ -0,0 +1,516 @@
+package api
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// plyDefaultColor is used for faces and points without color information
+var plyDefaultColor = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+
+// plyProperty describes a single (scalar or list) property of a PLY element
+type plyProperty struct {
+	name      string
+	dataType  string
+	isList    bool
+	countType string
+}
+
+// plyElement describes an element of a PLY file, e.g. `vertex` or `face`
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// plyReader reads property values in one of the three PLY formats
+type plyReader struct {
+	format  string
+	order   binary.ByteOrder
+	reader  *bufio.Reader
+	scanner *bufio.Scanner
+	buffer  [8]byte
+}
+
+// plyTypeSize returns the size in bytes of a PLY data type
+func plyTypeSize(dataType string) int {
+	switch dataType {
+	case "char", "int8", "uchar", "uint8":
+		return 1
+	case "short", "int16", "ushort", "uint16":
+		return 2
+	case "int", "int32", "uint", "uint32", "float", "float32":
+		return 4
+	case "double", "float64":
+		return 8
+	}
+	return 0
+}
+
+// plyIsFloat returns true for floating point PLY data types
+func plyIsFloat(dataType string) bool {
+	switch dataType {
+	case "float", "float32", "double", "float64":
+		return true
+	}
+	return false
+}
+
+// read reads a single value of the given type
+func (p *plyReader) read(dataType string) (float64, error) {
+	if p.format == "ascii" {
+		if !p.scanner.Scan() {
+			if err := p.scanner.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.ErrUnexpectedEOF
+		}
+		return strconv.ParseFloat(p.scanner.Text(), 64)
+	}
+
+	size := plyTypeSize(dataType)
+	if _, err := io.ReadFull(p.reader, p.buffer[:size]); err != nil {
+		return 0, err
+	}
+	data := p.buffer[:size]
+
+	switch dataType {
+	case "char", "int8":
+		return float64(int8(data[0])), nil
+	case "uchar", "uint8":
+		return float64(data[0]), nil
+	case "short", "int16":
+		return float64(int16(p.order.Uint16(data))), nil
+	case "ushort", "uint16":
+		return float64(p.order.Uint16(data)), nil
+	case "int", "int32":
+		return float64(int32(p.order.Uint32(data))), nil
+	case "uint", "uint32":
+		return float64(p.order.Uint32(data)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(p.order.Uint32(data))), nil
+	default:
+		return math.Float64frombits(p.order.Uint64(data)), nil
+	}
+}
+
+// parsePlyHeader reads the header up to and including `end_header`
+func parsePlyHeader(reader *bufio.Reader) (string, []plyElement, error) {
+	format := ""
+	var elements []plyElement
+
+	lineNumber := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid ply header: %v", err)
+		}
+		lineNumber++
+		line = strings.TrimSpace(line)
+		parts := strings.Fields(line)
+
+		if lineNumber == 1 {
+			if line != "ply" {
+				return "", nil, fmt.Errorf("invalid ply file: missing magic number")
+			}
+			continue
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		switch parts[0] {
+		case "format":
+			if len(parts) != 3 {
+				return "", nil, fmt.Errorf("invalid format line: '%s' in line %d", line, lineNumber)
+			}
+			format = parts[1]
+			if format != "ascii" && format != "binary_little_endian" && format != "binary_big_endian" {
+				return "", nil, fmt.Errorf("unsupported ply format '%s'", format)
+			}
+		case "element":
+			if len(parts) != 3 {
+				return "", nil, fmt.Errorf("invalid element line: '%s' in line %d", line, lineNumber)
+			}
+			count, err := strconv.Atoi(parts[2])
+			if err != nil || count < 0 {
+				return "", nil, fmt.Errorf("invalid element count: '%s' in line %d", line, lineNumber)
+			}
+			elements = append(elements, plyElement{name: parts[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return "", nil, fmt.Errorf("property without element: '%s' in line %d", line, lineNumber)
+			}
+			property := plyProperty{}
+			if len(parts) == 5 && parts[1] == "list" {
+				property = plyProperty{name: parts[4], dataType: parts[3], isList: true, countType: parts[2]}
+				if plyTypeSize(property.countType) == 0 || plyIsFloat(property.countType) {
+					return "", nil, fmt.Errorf("invalid list count type: '%s' in line %d", line, lineNumber)
+				}
+			} else if len(parts) == 3 {
+				property = plyProperty{name: parts[2], dataType: parts[1]}
+			} else {
+				return "", nil, fmt.Errorf("invalid property line: '%s' in line %d", line, lineNumber)
+			}
+			if plyTypeSize(property.dataType) == 0 {
+				return "", nil, fmt.Errorf("invalid property type: '%s' in line %d", line, lineNumber)
+			}
+			last := &elements[len(elements)-1]
+			last.properties = append(last.properties, property)
+		case "comment", "obj_info":
+			continue
+		case "end_header":
+			if format == "" {
+				return "", nil, fmt.Errorf("invalid ply header: missing format")
+			}
+			return format, elements, nil
+		default:
+			return "", nil, fmt.Errorf("unexpected header line: '%s' in line %d", line, lineNumber)
+		}
+	}
+}
+
+// LoadPLY implements support for ASCII and binary (little and big endian) PLY
+// files. Vertex positions, normals, colors and texture coordinates (u/v or s/t)
+// are read, polygonal faces of any size are split into triangles. Vertex colors
+// are kept per vertex, the face color is their average. Files without faces are
+// loaded as a point cloud
+func LoadPLY(r io.Reader) (*Mesh, error) {
+	reader := bufio.NewReader(r)
+	format, elements, err := parsePlyHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plyReader{format: format, reader: reader}
+	switch format {
+	case "ascii":
+		p.scanner = bufio.NewScanner(reader)
+		p.scanner.Split(bufio.ScanWords)
+	case "binary_little_endian":
+		p.order = binary.LittleEndian
+	case "binary_big_endian":
+		p.order = binary.BigEndian
+	}
+
+	var positions []Vector3d
+	var normals []Vector3d
+	var uvs []VectorUv
+	var colors []color.NRGBA
+	var faces [][]int
+
+	for _, element := range elements {
+		// Map well known properties to their position in the element
+		column := map[string]int{}
+		for i, property := range element.properties {
+			column[property.name] = i
+		}
+		has := func(names ...string) bool {
+			for _, name := range names {
+				if _, ok := column[name]; !ok {
+					return false
+				}
+			}
+			return true
+		}
+
+		isVertex := element.name == "vertex"
+		isFace := element.name == "face"
+		withNormals := isVertex && has("nx", "ny", "nz")
+		withColors := isVertex && has("red", "green", "blue")
+		uName, vName := "u", "v"
+		if has("s", "t") {
+			uName, vName = "s", "t"
+		} else if has("texture_u", "texture_v") {
+			uName, vName = "texture_u", "texture_v"
+		}
+		withUVs := isVertex && has(uName, vName)
+		if isVertex && !has("x", "y", "z") {
+			return nil, fmt.Errorf("vertex element without x, y and z properties")
+		}
+
+		values := make([]float64, len(element.properties))
+		for n := 0; n < element.count; n++ {
+			var face []int
+			for i, property := range element.properties {
+				if !property.isList {
+					value, err := p.read(property.dataType)
+					if err != nil {
+						return nil, fmt.Errorf("error reading %s %d: %v", element.name, n, err)
+					}
+					values[i] = value
+					continue
+				}
+
+				count, err := p.read(property.countType)
+				if err != nil {
+					return nil, fmt.Errorf("error reading %s %d: %v", element.name, n, err)
+				}
+				isIndexList := isFace && (property.name == "vertex_indices" || property.name == "vertex_index")
+				for k := 0; k < int(count); k++ {
+					value, err := p.read(property.dataType)
+					if err != nil {
+						return nil, fmt.Errorf("error reading %s %d: %v", element.name, n, err)
+					}
+					if isIndexList {
+						face = append(face, int(value))
+					}
+				}
+			}
+
+			if isVertex {
+				positions = append(positions, Vector3d{X: values[column["x"]], Y: values[column["y"]], Z: values[column["z"]], W: 1})
+				if withNormals {
+					normals = append(normals, Vector3d{X: values[column["nx"]], Y: values[column["ny"]], Z: values[column["nz"]]})
+				}
+				if withUVs {
+					uvs = append(uvs, VectorUv{U: values[column[uName]], V: values[column[vName]], W: 1})
+				}
+				if withColors {
+					channel := func(name string) uint8 {
+						value := values[column[name]]
+						if plyIsFloat(element.properties[column[name]].dataType) {
+							value *= 255
+						}
+						return uint8(math.Max(0, math.Min(255, math.Round(value))))
+					}
+					c := color.NRGBA{R: channel("red"), G: channel("green"), B: channel("blue"), A: 255}
+					if has("alpha") {
+						c.A = channel("alpha")
+					}
+					colors = append(colors, c)
+				}
+			} else if isFace {
+				faces = append(faces, face)
+			}
+		}
+	}
+
+	result := NewMesh()
+
+	if len(faces) == 0 {
+		for i, position := range positions {
+			point := Point{Position: position, Color: plyDefaultColor}
+			if normals != nil {
+				point.Normal = normals[i]
+			}
+			if colors != nil {
+				point.Color = colors[i]
+			}
+			result.AddPoint(point)
+		}
+		return result, nil
+	}
+
+	for n, face := range faces {
+		if len(face) < 3 {
+			return nil, fmt.Errorf("face %d has less than three vertices", n)
+		}
+		for _, index := range face {
+			if index < 0 || index >= len(positions) {
+				return nil, fmt.Errorf("face %d references invalid vertex %d", n, index)
+			}
+		}
+
+		// Polygons are split into a triangle fan
+		for i := 1; i+1 < len(face); i++ {
+			indices := [3]int{face[0], face[i], face[i+1]}
+			triangle := Triangle{}
+			r, g, b, a := 0.0, 0.0, 0.0, 0.0
+			for v, index := range indices {
+				triangle.Vertices[v] = positions[index]
+				if normals != nil {
+					triangle.Normals[v] = normals[index]
+				}
+				if uvs != nil {
+					triangle.UVs[v] = uvs[index]
+				}
+				if colors != nil {
+					triangle.VertexColors[v] = colors[index]
+					r += float64(colors[index].R) / 3
+					g += float64(colors[index].G) / 3
+					b += float64(colors[index].B) / 3
+					a += float64(colors[index].A) / 3
+				}
+			}
+
+			if colors != nil {
+				triangle.Color = color.NRGBA{R: uint8(math.Round(r)), G: uint8(math.Round(g)), B: uint8(math.Round(b)), A: uint8(math.Round(a))}
+			} else if uvs == nil {
+				triangle.Color = plyDefaultColor
+			}
+			result.AddTriangle(triangle)
+		}
+	}
+
+	return result, nil
+}
+
+// plyVertex is a unique combination of vertex attributes written to a PLY file
+type plyVertex struct {
+	position [3]float64
+	normal   [3]float64
+	uv       [2]float64
+	color    color.NRGBA
+}
+
+// WritePLY writes the mesh in PLY format, either ASCII or binary little endian.
+// Vertex colors are written as they are, otherwise triangle colors are written
+// as vertex colors and textured triangles with white vertices. Normals and
+// texture coordinates are only written if any triangle has them. Points of a
+// point cloud are written as vertices without faces
+func (m *Mesh) WritePLY(w io.Writer, binaryFormat bool) error {
+	var vertices []plyVertex
+	vertexIndices := map[plyVertex]int{}
+	faces := make([][3]int, 0, len(m.triangles))
+
+	withNormals := false
+	withUVs := false
+	for _, triangle := range m.triangles {
+		withNormals = withNormals || triangle.hasNormals()
+		withUVs = withUVs || triangle.Color == nil
+	}
+	for _, point := range m.points {
+		withNormals = withNormals || point.Normal.X != 0 || point.Normal.Y != 0 || point.Normal.Z != 0
+	}
+
+	addVertex := func(vertex plyVertex) int {
+		index, ok := vertexIndices[vertex]
+		if !ok {
+			index = len(vertices)
+			vertexIndices[vertex] = index
+			vertices = append(vertices, vertex)
+		}
+		return index
+	}
+
+	// Points are written as vertices without faces
+	for _, point := range m.points {
+		c := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+		if point.Color != nil {
+			c = color.NRGBAModel.Convert(point.Color).(color.NRGBA)
+		}
+		vertex := plyVertex{
+			position: [3]float64{point.Position.X, point.Position.Y, point.Position.Z},
+			color:    c,
+		}
+		if withNormals {
+			vertex.normal = [3]float64{point.Normal.X, point.Normal.Y, point.Normal.Z}
+		}
+		addVertex(vertex)
+	}
+
+	for _, triangle := range m.triangles {
+		c := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+		if triangle.Color != nil {
+			c = color.NRGBAModel.Convert(triangle.Color).(color.NRGBA)
+		}
+
+		face := [3]int{}
+		for i := range triangle.Vertices {
+			vertex := plyVertex{
+				position: [3]float64{triangle.Vertices[i].X, triangle.Vertices[i].Y, triangle.Vertices[i].Z},
+				color:    c,
+			}
+			if triangle.VertexColors[i] != nil {
+				vertex.color = color.NRGBAModel.Convert(triangle.VertexColors[i]).(color.NRGBA)
+			}
+			if withNormals {
+				vertex.normal = [3]float64{triangle.Normals[i].X, triangle.Normals[i].Y, triangle.Normals[i].Z}
+			}
+			if withUVs && triangle.Color == nil {
+				vertex.uv = [2]float64{triangle.UVs[i].U, triangle.UVs[i].V}
+			}
+
+			face[i] = addVertex(vertex)
+		}
+		faces = append(faces, face)
+	}
+
+	writer := bufio.NewWriter(w)
+
+	format := "ascii"
+	if binaryFormat {
+		format = "binary_little_endian"
+	}
+	header := fmt.Sprintf("ply\nformat %s 1.0\ncomment written by mini3d\nelement vertex %d\n", format, len(vertices))
+	header += "property float x\nproperty float y\nproperty float z\n"
+	if withNormals {
+		header += "property float nx\nproperty float ny\nproperty float nz\n"
+	}
+	if withUVs {
+		header += "property float s\nproperty float t\n"
+	}
+	header += "property uchar red\nproperty uchar green\nproperty uchar blue\nproperty uchar alpha\n"
+	header += fmt.Sprintf("element face %d\nproperty list uchar int vertex_indices\nend_header\n", len(faces))
+	if _, err := writer.WriteString(header); err != nil {
+		return err
+	}
+
+	if binaryFormat {
+		buffer := make([]byte, 0, 48)
+		putFloat := func(f float64) {
+			buffer = binary.LittleEndian.AppendUint32(buffer, math.Float32bits(float32(f)))
+		}
+
+		for _, vertex := range vertices {
+			buffer = buffer[:0]
+			putFloat(vertex.position[0])
+			putFloat(vertex.position[1])
+			putFloat(vertex.position[2])
+			if withNormals {
+				putFloat(vertex.normal[0])
+				putFloat(vertex.normal[1])
+				putFloat(vertex.normal[2])
+			}
+			if withUVs {
+				putFloat(vertex.uv[0])
+				putFloat(vertex.uv[1])
+			}
+			buffer = append(buffer, vertex.color.R, vertex.color.G, vertex.color.B, vertex.color.A)
+			if _, err := writer.Write(buffer); err != nil {
+				return err
+			}
+		}
+
+		for _, face := range faces {
+			buffer = append(buffer[:0], 3)
+			for _, index := range face {
+				buffer = binary.LittleEndian.AppendUint32(buffer, uint32(index))
+			}
+			if _, err := writer.Write(buffer); err != nil {
+				return err
+			}
+		}
+
+		return writer.Flush()
+	}
+
+	for _, vertex := range vertices {
+		line := fmt.Sprintf("%s %s %s", objFloat(vertex.position[0]), objFloat(vertex.position[1]), objFloat(vertex.position[2]))
+		if withNormals {
+			line += fmt.Sprintf(" %s %s %s", objFloat(vertex.normal[0]), objFloat(vertex.normal[1]), objFloat(vertex.normal[2]))
+		}
+		if withUVs {
+			line += fmt.Sprintf(" %s %s", objFloat(vertex.uv[0]), objFloat(vertex.uv[1]))
+		}
+		line += fmt.Sprintf(" %d %d %d %d\n", vertex.color.R, vertex.color.G, vertex.color.B, vertex.color.A)
+		if _, err := writer.WriteString(line); err != nil {
+			return err
+		}
+	}
+
+	for _, face := range faces {
+		if _, err := fmt.Fprintf(writer, "3 %d %d %d\n", face[0], face[1], face[2]); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
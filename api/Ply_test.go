@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestMesh_WritePLY(t *testing.T) {
+	for _, binaryFormat := range []bool{true, false} {
+		mesh := ColoredCube()
+
+		buffer := &bytes.Buffer{}
+		if err := mesh.WritePLY(buffer, binaryFormat); err != nil {
+			t.Fatalf("WritePLY(binary=%v): %v", binaryFormat, err)
+		}
+
+		loaded, err := LoadPLY(buffer)
+		if err != nil {
+			t.Fatalf("LoadPLY(binary=%v): %v", binaryFormat, err)
+		}
+		if len(loaded.triangles) != len(mesh.triangles) {
+			t.Fatalf("binary=%v: expected %d triangles, got %d", binaryFormat, len(mesh.triangles), len(loaded.triangles))
+		}
+
+		for i, triangle := range loaded.triangles {
+			expected := mesh.triangles[i]
+			if triangle.Vertices != expected.Vertices {
+				t.Fatalf("binary=%v: triangle %d: expected %v, got %v", binaryFormat, i, expected.Vertices, triangle.Vertices)
+			}
+			if color.NRGBAModel.Convert(triangle.Color) != color.NRGBAModel.Convert(expected.Color) {
+				t.Fatalf("binary=%v: triangle %d: expected color %v, got %v", binaryFormat, i, expected.Color, triangle.Color)
+			}
+		}
+	}
+}
+
+func TestLoadPLY_PointCloud(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	buffer.WriteString("ply\nformat binary_big_endian 1.0\nelement vertex 2\n")
+	buffer.WriteString("property double x\nproperty double y\nproperty double z\n")
+	buffer.WriteString("property uchar red\nproperty uchar green\nproperty uchar blue\nend_header\n")
+	for _, point := range [][3]float64{{1, 2, 3}, {4, 5, 6}} {
+		for _, f := range point {
+			_ = binary.Write(buffer, binary.BigEndian, math.Float64bits(f))
+		}
+		buffer.Write([]byte{255, 0, 0})
+	}
+
+	mesh, err := LoadPLY(buffer)
+	if err != nil {
+		t.Fatalf("LoadPLY: %v", err)
+	}
+	if len(mesh.triangles) != 0 || len(mesh.points) != 2 {
+		t.Fatalf("expected 2 points and no triangles, got %d points and %d triangles", len(mesh.points), len(mesh.triangles))
+	}
+	if mesh.points[1].Position != (Vector3d{X: 4, Y: 5, Z: 6, W: 1}) {
+		t.Fatalf("unexpected position %v", mesh.points[1].Position)
+	}
+	if mesh.points[1].Color != (color.NRGBA{R: 255, A: 255}) {
+		t.Fatalf("unexpected color %v", mesh.points[1].Color)
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"image/color"
+	"time"
+)
+
+// Frame is a rendered frame as seen by post effects
+type Frame struct {
+	W, H int
+
+	// Colors of the pixels row by row, alpha premultiplied. Pixels without
+	// geometry or background are transparent
+	Color []color.RGBA
+
+	// Depth of the pixels like in the depth buffer: the interpolated 1/w, larger
+	// values are closer and pixels without geometry are 0
+	Depth []float64
+}
+
+// At returns the color of a pixel, coordinates outside the frame are clamped to
+// its edges
+func (f *Frame) At(x, y int) color.RGBA {
+	x = max(0, min(f.W-1, x))
+	y = max(0, min(f.H-1, y))
+	return f.Color[y*f.W+x]
+}
+
+// DepthAt returns the depth of a pixel, coordinates outside the frame are clamped
+// to its edges
+func (f *Frame) DepthAt(x, y int) float64 {
+	x = max(0, min(f.W-1, x))
+	y = max(0, min(f.H-1, y))
+	return f.Depth[y*f.W+x]
+}
+
+// PostEffect processes a rendered frame before it is handed to the hooks. Effects
+// are chained, each one reads the output of the previous one
+type PostEffect interface {
+	// Apply writes the processed frame to dst, which holds a pixel for every
+	// pixel of the frame
+	Apply(frame *Frame, dst []color.RGBA)
+}
+
+// SetPostEffects sets the chain of effects applied to every frame, none turns
+// post processing off. With post effects the frame is rendered into a color
+// buffer first and every pixel of the target is handed to the hooks afterwards
+func (e *Engine) SetPostEffects(effects ...PostEffect) {
+	e.postEffects = effects
+	if len(effects) == 0 {
+		return
+	}
+	w, h := e.targetSize()
+	if len(e.frameBuffer.Color) != w*h {
+		e.frameBuffer = Frame{
+			W:     w,
+			H:     h,
+			Color: make([]color.RGBA, w*h),
+			Depth: make([]float64, w*h),
+		}
+		e.postBuffer = make([]color.RGBA, w*h)
+	}
+	if e.bufferSpan == nil {
+		e.bufferSpan = e.writeFrameBuffer
+	}
+}
+
+// PostEffects returns the chain of post effects
+func (e *Engine) PostEffects() []PostEffect {
+	return e.postEffects
+}
+
+// writeFrameBuffer replaces the hooks while a frame is rendered for post
+// processing
+func (e *Engine) writeFrameBuffer(y, x0, x1 int, colors []color.RGBA, userData UserData) {
+	row := y * e.frameBuffer.W
+	copy(e.frameBuffer.Color[row+x0:row+x1], colors)
+}
+
+// copyDepth copies the depth buffer of the current view into the frame. With
+// anti-aliasing a pixel gets the closest depth of its samples
+func (e *Engine) copyDepth() {
+	scale := e.sampleScale()
+	if scale == 1 {
+		for y := 0; y < e.h; y++ {
+			row := (e.offsetY+y)*e.frameBuffer.W + e.offsetX
+			copy(e.frameBuffer.Depth[row:row+e.w], e.depthBuffer.Entries[y*e.w:(y+1)*e.w])
+		}
+		return
+	}
+
+	for y := 0; y < e.h; y++ {
+		row := ((e.offsetY+y)/scale)*e.frameBuffer.W + e.offsetX/scale
+		for x, depth := range e.depthBuffer.Entries[y*e.w : (y+1)*e.w] {
+			pixel := &e.frameBuffer.Depth[row+x/scale]
+			*pixel = max(*pixel, depth)
+		}
+	}
+}
+
+// postProcess applies the post effects to the frame and hands it to the hooks
+func (e *Engine) postProcess(userData UserData) {
+	start := time.Now()
+	for _, effect := range e.postEffects {
+		effect.Apply(&e.frameBuffer, e.postBuffer)
+		e.frameBuffer.Color, e.postBuffer = e.postBuffer, e.frameBuffer.Color
+	}
+
+	flushed := time.Now()
+	e.Metrics.PostProcessingTime += flushed.Sub(start)
+
+	frame := &e.frameBuffer
+	for y := 0; y < frame.H; y++ {
+		row := frame.Color[y*frame.W : (y+1)*frame.W]
+		if e.drawSpan != nil {
+			e.drawSpan(y, 0, frame.W, row, userData)
+			continue
+		}
+		for x, c := range row {
+			e.drawPixel(x, y, c, userData)
+		}
+	}
+	e.Metrics.FlushTime += time.Since(flushed)
+}
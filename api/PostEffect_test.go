@@ -0,0 +1,147 @@
+package api
+
+import (
+	"image/color"
+	"testing"
+)
+
+// effectFunc adapts a function to the `PostEffect` interface
+type effectFunc func(frame *Frame, dst []color.RGBA)
+
+func (f effectFunc) Apply(frame *Frame, dst []color.RGBA) {
+	f(frame, dst)
+}
+
+func TestEngine_PostEffects(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	drawn := map[[2]int]int{}
+	engine := NewEngine(32, 32, 90, func(x, y int, c color.Color, userData UserData) {
+		drawn[[2]int{x, y}]++
+		if color.RGBAModel.Convert(c).(color.RGBA) != white {
+			t.Fatalf("pixel %d/%d: expected the output of the last effect", x, y)
+		}
+	}, nil)
+	engine.AddMesh(ColoredCube())
+	engine.SetCameraPositionAbsolute(0.5, 0.5, -1, 0, 0)
+
+	var order []int
+	engine.SetPostEffects(
+		effectFunc(func(frame *Frame, dst []color.RGBA) {
+			order = append(order, 1)
+			if frame.DepthAt(16, 16) <= 0 || frame.DepthAt(0, 0) != 0 {
+				t.Fatalf("expected the depth of the cube in the frame")
+			}
+			if frame.At(16, 16).A == 0 || frame.At(0, 0).A != 0 {
+				t.Fatalf("expected the cube in the frame")
+			}
+			copy(dst, frame.Color)
+		}),
+		effectFunc(func(frame *Frame, dst []color.RGBA) {
+			order = append(order, 2)
+			for i := range dst {
+				dst[i] = white
+			}
+		}),
+	)
+	engine.Render(nil)
+
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Fatalf("expected the effects to be applied in order, got %v", order)
+	}
+	if len(drawn) != 32*32 {
+		t.Fatalf("expected every pixel to be flushed, got %d", len(drawn))
+	}
+	for position, n := range drawn {
+		if n != 1 {
+			t.Fatalf("pixel %v: expected to be flushed once, got %d", position, n)
+		}
+	}
+}
+
+// testFrame returns a frame with the left half black and the right half white
+func testFrame() *Frame {
+	frame := &Frame{W: 8, H: 8, Color: make([]color.RGBA, 64), Depth: make([]float64, 64)}
+	for i := range frame.Color {
+		frame.Color[i] = color.RGBA{A: 255}
+		if i%8 >= 4 {
+			frame.Color[i] = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+		}
+	}
+	return frame
+}
+
+func TestGaussianBlur(t *testing.T) {
+	frame := testFrame()
+	dst := make([]color.RGBA, 64)
+	NewGaussianBlur(2).Apply(frame, dst)
+
+	if dst[3].R == 0 || dst[4].R == 255 || dst[3].R >= dst[4].R {
+		t.Fatalf("expected the edge to be smoothed, got %v and %v", dst[3], dst[4])
+	}
+	if dst[0].R > 5 || dst[7].R < 250 {
+		t.Fatalf("expected the sides to stay, got %v and %v", dst[0], dst[7])
+	}
+}
+
+func TestVignette(t *testing.T) {
+	frame := testFrame()
+	dst := make([]color.RGBA, 64)
+	NewVignette(1).Apply(frame, dst)
+
+	if dst[7].R >= 128 {
+		t.Fatalf("expected the corner to be darkened, got %v", dst[7])
+	}
+	if dst[3*8+4].R != 255 {
+		t.Fatalf("expected the center to stay, got %v", dst[3*8+4])
+	}
+}
+
+func TestColorGrading(t *testing.T) {
+	frame := testFrame()
+	frame.Color[0] = color.RGBA{R: 200, G: 100, B: 30, A: 255}
+	dst := make([]color.RGBA, 64)
+
+	identity := NewLUT3D(17, func(r, g, b float64) (float64, float64, float64) {
+		return r, g, b
+	})
+	NewColorGrading(identity).Apply(frame, dst)
+	for i := range dst {
+		if d := dst[i]; d != frame.Color[i] {
+			t.Fatalf("pixel %d: expected the identity to keep %v, got %v", i, frame.Color[i], d)
+		}
+	}
+
+	invert := NewLUT3D(2, func(r, g, b float64) (float64, float64, float64) {
+		return 1 - r, 1 - g, 1 - b
+	})
+	NewColorGrading(invert).Apply(frame, dst)
+	if expected := (color.RGBA{R: 55, G: 155, B: 225, A: 255}); dst[0] != expected {
+		t.Fatalf("expected %v, got %v", expected, dst[0])
+	}
+}
+
+func TestOutline(t *testing.T) {
+	frame := testFrame()
+	red := color.RGBA{R: 255, A: 255}
+
+	// A near square in front of a far wall
+	for i := range frame.Depth {
+		frame.Depth[i] = 0.1
+	}
+	for y := 2; y < 6; y++ {
+		for x := 2; x < 6; x++ {
+			frame.Depth[y*8+x] = 1
+		}
+	}
+	dst := make([]color.RGBA, 64)
+	NewOutline(red).Apply(frame, dst)
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			edge := (x == 2 || x == 5 || y == 2 || y == 5) && x >= 2 && x < 6 && y >= 2 && y < 6
+			if got := dst[y*8+x] == red; got != edge {
+				t.Fatalf("pixel %d/%d: expected outline %v, got %v", x, y, edge, dst[y*8+x])
+			}
+		}
+	}
+}
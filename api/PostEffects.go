@@ -0,0 +1,421 @@
+package api
+
+import (
+	"image/color"
+	"math"
+)
+
+// colorF is a color with float channels from 0 to 255 used by the post effects,
+// alpha premultiplied like `color.RGBA`
+type colorF [4]float64
+
+func toColorF(c color.RGBA) colorF {
+	return colorF{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+}
+
+func (c colorF) add(other colorF) colorF {
+	return colorF{c[0] + other[0], c[1] + other[1], c[2] + other[2], c[3] + other[3]}
+}
+
+func (c colorF) scale(f float64) colorF {
+	return colorF{c[0] * f, c[1] * f, c[2] * f, c[3] * f}
+}
+
+// luma returns the perceived brightness from 0 to 1
+func (c colorF) luma() float64 {
+	return (0.299*c[0] + 0.587*c[1] + 0.114*c[2]) / 255
+}
+
+// rgba rounds the color and clamps it to the valid range
+func (c colorF) rgba() color.RGBA {
+	channel := func(v float64) uint8 {
+		return uint8(max(0, min(255, math.Round(v))))
+	}
+	return color.RGBA{R: channel(c[0]), G: channel(c[1]), B: channel(c[2]), A: channel(c[3])}
+}
+
+// sample returns the bilinear interpolated color at a position in pixels, pixel
+// centers lie at +0.5
+func (f *Frame) sample(x, y float64) colorF {
+	x -= 0.5
+	y -= 0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	tx, ty := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+
+	top := toColorF(f.At(ix, iy)).scale(1 - tx).add(toColorF(f.At(ix+1, iy)).scale(tx))
+	bottom := toColorF(f.At(ix, iy+1)).scale(1 - tx).add(toColorF(f.At(ix+1, iy+1)).scale(tx))
+	return top.scale(1 - ty).add(bottom.scale(ty))
+}
+
+// FXAA smooths jagged edges by blending pixels along the edges found in the luma
+// of the frame
+type FXAA struct {
+	// Threshold is the minimum contrast in luma around a pixel that is treated as
+	// an edge
+	Threshold float64
+
+	// SpanMax is the farthest distance in pixels blended along an edge
+	SpanMax float64
+}
+
+// Fixed parameters of the edge direction estimate
+const (
+	fxaaReduceMin = 1.0 / 128
+	fxaaReduceMul = 1.0 / 8
+)
+
+// NewFXAA creates an FXAA effect with the usual settings
+func NewFXAA() *FXAA {
+	return &FXAA{Threshold: 1.0 / 16, SpanMax: 8}
+}
+
+func (f *FXAA) Apply(frame *Frame, dst []color.RGBA) {
+	for y := 0; y < frame.H; y++ {
+		for x := 0; x < frame.W; x++ {
+			center := frame.At(x, y)
+			lumaM := toColorF(center).luma()
+			lumaNW := toColorF(frame.At(x-1, y-1)).luma()
+			lumaNE := toColorF(frame.At(x+1, y-1)).luma()
+			lumaSW := toColorF(frame.At(x-1, y+1)).luma()
+			lumaSE := toColorF(frame.At(x+1, y+1)).luma()
+			lumaMin := min(lumaM, lumaNW, lumaNE, lumaSW, lumaSE)
+			lumaMax := max(lumaM, lumaNW, lumaNE, lumaSW, lumaSE)
+			if lumaMax-lumaMin < f.Threshold {
+				dst[y*frame.W+x] = center
+				continue
+			}
+
+			// The direction along the edge, scaled so that its shorter
+			// component is about one pixel
+			dirX := -((lumaNW + lumaNE) - (lumaSW + lumaSE))
+			dirY := (lumaNW + lumaSW) - (lumaNE + lumaSE)
+			reduce := max((lumaNW+lumaNE+lumaSW+lumaSE)*0.25*fxaaReduceMul, fxaaReduceMin)
+			scale := 1 / (min(math.Abs(dirX), math.Abs(dirY)) + reduce)
+			dirX = max(-f.SpanMax, min(f.SpanMax, dirX*scale))
+			dirY = max(-f.SpanMax, min(f.SpanMax, dirY*scale))
+
+			cx, cy := float64(x)+0.5, float64(y)+0.5
+			inner := frame.sample(cx+dirX*(1.0/3-0.5), cy+dirY*(1.0/3-0.5)).
+				add(frame.sample(cx+dirX*(2.0/3-0.5), cy+dirY*(2.0/3-0.5))).scale(0.5)
+			outer := inner.scale(0.5).
+				add(frame.sample(cx-dirX*0.5, cy-dirY*0.5).add(frame.sample(cx+dirX*0.5, cy+dirY*0.5)).scale(0.25))
+
+			// The wider blend may cross into another edge
+			if l := outer.luma(); l < lumaMin || l > lumaMax {
+				dst[y*frame.W+x] = inner.rgba()
+			} else {
+				dst[y*frame.W+x] = outer.rgba()
+			}
+		}
+	}
+}
+
+// GaussianBlur blurs the frame with a separable gaussian kernel
+type GaussianBlur struct {
+	// Radius of the kernel in pixels
+	Radius int
+
+	// Sigma is the standard deviation of the kernel, half the radius if not set
+	Sigma float64
+
+	kernel []float64
+	temp   []color.RGBA
+}
+
+// NewGaussianBlur creates a blur with the given radius in pixels
+func NewGaussianBlur(radius int) *GaussianBlur {
+	return &GaussianBlur{Radius: radius}
+}
+
+func (b *GaussianBlur) Apply(frame *Frame, dst []color.RGBA) {
+	b.blur(frame.Color, dst, frame.W, frame.H)
+}
+
+// blur blurs src into dst, first horizontally and then vertically
+func (b *GaussianBlur) blur(src, dst []color.RGBA, w, h int) {
+	radius := max(b.Radius, 0)
+	sigma := b.Sigma
+	if sigma <= 0 {
+		sigma = max(float64(radius)/2, 0.5)
+	}
+
+	b.kernel = b.kernel[:0]
+	total := 0.0
+	for i := -radius; i <= radius; i++ {
+		weight := math.Exp(-float64(i*i) / (2 * sigma * sigma))
+		b.kernel = append(b.kernel, weight)
+		total += weight
+	}
+	for i := range b.kernel {
+		b.kernel[i] /= total
+	}
+	if len(b.temp) != w*h {
+		b.temp = make([]color.RGBA, w*h)
+	}
+
+	pass := func(src, dst []color.RGBA, dx, dy int) {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				sum := colorF{}
+				for i, weight := range b.kernel {
+					sx := max(0, min(w-1, x+(i-radius)*dx))
+					sy := max(0, min(h-1, y+(i-radius)*dy))
+					sum = sum.add(toColorF(src[sy*w+sx]).scale(weight))
+				}
+				dst[y*w+x] = sum.rgba()
+			}
+		}
+	}
+	pass(src, b.temp, 1, 0)
+	pass(b.temp, dst, 0, 1)
+}
+
+// Bloom lets bright parts of the frame glow into their surroundings
+type Bloom struct {
+	// Threshold is the luma from 0 to 1 above which pixels glow
+	Threshold float64
+
+	// Intensity scales the glow added to the frame
+	Intensity float64
+
+	// Radius of the glow in pixels
+	Radius int
+
+	blur    GaussianBlur
+	bright  []color.RGBA
+	blurred []color.RGBA
+}
+
+// NewBloom creates a bloom effect
+func NewBloom(threshold, intensity float64, radius int) *Bloom {
+	return &Bloom{Threshold: threshold, Intensity: intensity, Radius: radius}
+}
+
+func (b *Bloom) Apply(frame *Frame, dst []color.RGBA) {
+	if len(b.bright) != len(frame.Color) {
+		b.bright = make([]color.RGBA, len(frame.Color))
+		b.blurred = make([]color.RGBA, len(frame.Color))
+	}
+
+	for i, c := range frame.Color {
+		b.bright[i] = color.RGBA{}
+		if toColorF(c).luma() > b.Threshold {
+			b.bright[i] = c
+		}
+	}
+	b.blur.Radius = b.Radius
+	b.blur.blur(b.bright, b.blurred, frame.W, frame.H)
+
+	for i, c := range frame.Color {
+		dst[i] = toColorF(c).add(toColorF(b.blurred[i]).scale(b.Intensity)).rgba()
+	}
+}
+
+// Vignette darkens the frame towards its corners
+type Vignette struct {
+	// Strength is how much the corners are darkened, from 0 to 1
+	Strength float64
+
+	// Radius is the distance from the center where the darkening starts, 1 is
+	// the distance of the corners
+	Radius float64
+}
+
+// NewVignette creates a vignette that darkens the corners by the given strength
+func NewVignette(strength float64) *Vignette {
+	return &Vignette{Strength: strength, Radius: 0.5}
+}
+
+func (v *Vignette) Apply(frame *Frame, dst []color.RGBA) {
+	cx, cy := float64(frame.W)/2, float64(frame.H)/2
+	for y := 0; y < frame.H; y++ {
+		for x := 0; x < frame.W; x++ {
+			distance := math.Hypot((float64(x)+0.5-cx)/cx, (float64(y)+0.5-cy)/cy) / math.Sqrt2
+
+			// Smooth step from the radius to the corners
+			t := 0.0
+			if v.Radius < 1 {
+				t = max(0, min(1, (distance-v.Radius)/(1-v.Radius)))
+			}
+			t = t * t * (3 - 2*t)
+
+			c := toColorF(frame.Color[y*frame.W+x])
+			factor := 1 - v.Strength*t
+			dst[y*frame.W+x] = colorF{c[0] * factor, c[1] * factor, c[2] * factor, c[3]}.rgba()
+		}
+	}
+}
+
+// LUT3D is a color lookup table, a cube of Size x Size x Size colors with red
+// changing fastest and blue slowest. Colors between the entries are interpolated
+type LUT3D struct {
+	Size int
+	Data []color.RGBA
+}
+
+// NewLUT3D creates a lookup table of the given size from a grading function that
+// maps colors with channels from 0 to 1
+func NewLUT3D(size int, grade func(r, g, b float64) (float64, float64, float64)) *LUT3D {
+	size = max(size, 2)
+	lut := &LUT3D{Size: size, Data: make([]color.RGBA, 0, size*size*size)}
+	step := 1 / float64(size-1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				gr, gg, gb := grade(float64(r)*step, float64(g)*step, float64(b)*step)
+				lut.Data = append(lut.Data, colorF{gr * 255, gg * 255, gb * 255, 255}.rgba())
+			}
+		}
+	}
+	return lut
+}
+
+// lookup returns the trilinear interpolated entry for channels from 0 to 1
+func (l *LUT3D) lookup(r, g, b float64) colorF {
+	n := l.Size - 1
+	split := func(v float64) (int, float64) {
+		p := max(0, min(1, v)) * float64(n)
+		i := min(int(p), n-1)
+		return i, p - float64(i)
+	}
+	ri, rt := split(r)
+	gi, gt := split(g)
+	bi, bt := split(b)
+
+	at := func(r, g, b int) colorF {
+		return toColorF(l.Data[(b*l.Size+g)*l.Size+r])
+	}
+	lerp := func(a, b colorF, t float64) colorF {
+		return a.scale(1 - t).add(b.scale(t))
+	}
+	c00 := lerp(at(ri, gi, bi), at(ri+1, gi, bi), rt)
+	c10 := lerp(at(ri, gi+1, bi), at(ri+1, gi+1, bi), rt)
+	c01 := lerp(at(ri, gi, bi+1), at(ri+1, gi, bi+1), rt)
+	c11 := lerp(at(ri, gi+1, bi+1), at(ri+1, gi+1, bi+1), rt)
+	return lerp(lerp(c00, c10, gt), lerp(c01, c11, gt), bt)
+}
+
+// ColorGrading maps the colors of the frame through a 3D lookup table
+type ColorGrading struct {
+	LUT *LUT3D
+}
+
+// NewColorGrading creates a color grading effect with the given lookup table
+func NewColorGrading(lut *LUT3D) *ColorGrading {
+	return &ColorGrading{LUT: lut}
+}
+
+func (g *ColorGrading) Apply(frame *Frame, dst []color.RGBA) {
+	for i, c := range frame.Color {
+		if c.A == 0 {
+			dst[i] = c
+			continue
+		}
+
+		// The table maps straight colors, undo the premultiplied alpha
+		alpha := float64(c.A) / 255
+		graded := g.LUT.lookup(float64(c.R)/255/alpha, float64(c.G)/255/alpha, float64(c.B)/255/alpha)
+		dst[i] = colorF{graded[0] * alpha, graded[1] * alpha, graded[2] * alpha, float64(c.A)}.rgba()
+	}
+}
+
+// Sharpen increases the contrast between neighbouring pixels
+type Sharpen struct {
+	// Amount of sharpening, 0 leaves the frame unchanged
+	Amount float64
+}
+
+// NewSharpen creates a sharpen effect with the given amount
+func NewSharpen(amount float64) *Sharpen {
+	return &Sharpen{Amount: amount}
+}
+
+func (s *Sharpen) Apply(frame *Frame, dst []color.RGBA) {
+	for y := 0; y < frame.H; y++ {
+		for x := 0; x < frame.W; x++ {
+			center := toColorF(frame.At(x, y))
+			neighbours := toColorF(frame.At(x-1, y)).
+				add(toColorF(frame.At(x+1, y))).
+				add(toColorF(frame.At(x, y-1))).
+				add(toColorF(frame.At(x, y+1)))
+			detail := center.scale(4).add(neighbours.scale(-1))
+			dst[y*frame.W+x] = center.add(detail.scale(s.Amount)).rgba()
+		}
+	}
+}
+
+// ChromaticAberration splits the red and blue channels towards the edges of the
+// frame like a cheap lens does
+type ChromaticAberration struct {
+	// Amount is the offset of the channels at the edges in pixels
+	Amount float64
+}
+
+// NewChromaticAberration creates a chromatic aberration with the given offset in
+// pixels
+func NewChromaticAberration(amount float64) *ChromaticAberration {
+	return &ChromaticAberration{Amount: amount}
+}
+
+func (a *ChromaticAberration) Apply(frame *Frame, dst []color.RGBA) {
+	cx, cy := float64(frame.W)/2, float64(frame.H)/2
+	for y := 0; y < frame.H; y++ {
+		for x := 0; x < frame.W; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			ox, oy := (px-cx)/cx*a.Amount, (py-cy)/cy*a.Amount
+
+			c := toColorF(frame.Color[y*frame.W+x])
+			c[0] = frame.sample(px+ox, py+oy)[0]
+			c[2] = frame.sample(px-ox, py-oy)[2]
+			dst[y*frame.W+x] = c.rgba()
+		}
+	}
+}
+
+// Outline draws lines along the silhouettes and creases found in the depth of the
+// frame. Lines are drawn on the closer side of an edge
+type Outline struct {
+	Color color.RGBA
+
+	// Threshold is the relative difference in distance between neighbouring
+	// pixels that is treated as an edge
+	Threshold float64
+}
+
+// NewOutline creates an outline in the given color
+func NewOutline(c color.RGBA) *Outline {
+	return &Outline{Color: c, Threshold: 0.1}
+}
+
+func (o *Outline) Apply(frame *Frame, dst []color.RGBA) {
+	offsets := [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	line := toColorF(o.Color)
+
+	for y := 0; y < frame.H; y++ {
+		for x := 0; x < frame.W; x++ {
+			depth := frame.Depth[y*frame.W+x]
+			edge := false
+			for _, offset := range offsets {
+				neighbour := frame.DepthAt(x+offset[0], y+offset[1])
+				if depth <= 0 || neighbour >= depth {
+					continue
+				}
+
+				// The depth holds 1/w, compare the distances. Pixels without
+				// geometry are infinitely far away
+				if neighbour <= 0 || (1/neighbour-1/depth)*depth > o.Threshold {
+					edge = true
+					break
+				}
+			}
+
+			c := frame.Color[y*frame.W+x]
+			if edge {
+				// Blend the line over the pixel
+				c = line.add(toColorF(c).scale(1 - line[3]/255)).rgba()
+			}
+			dst[y*frame.W+x] = c
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package api
+
+import "math"
+
+// The generators in this file produce textured meshes centered at origin, with
+// texture coordinates and vertex normals. Triangles are wound so that their normal
+// points outwards, which is what the visibility test in `renderMesh` expects.
+// Segment counts below the minimum required for a closed shape are raised to it
+
+// profilePoint is a point of a 2D profile that is revolved around the Y axis
+type profilePoint struct {
+	// Distance from the Y axis and height
+	radius, y float64
+
+	// Outward normal in the profile plane
+	normalRadius, normalY float64
+
+	// Texture coordinate along the profile, from 0 (bottom) to 1 (top)
+	v float64
+}
+
+// addPrimitiveTriangle adds a triangle unless it is degenerate, e.g. at the
+// poles of a sphere
+func addPrimitiveTriangle(mesh *Mesh, vertices [3]Vector3d, uvs [3]VectorUv, normals [3]Vector3d) {
+	l1 := vertices[1].Sub(&vertices[0])
+	l2 := vertices[2].Sub(&vertices[0])
+	cross := l1.Cross(&l2)
+	if cross.Len() < 1e-12 {
+		return
+	}
+	mesh.AddTriangle(Triangle{
+		Vertices: vertices,
+		UVs:      uvs,
+		Normals:  normals,
+	})
+}
+
+// addSurface adds a surface given as a grid of (uSegments + 1) * (vSegments + 1)
+// vertices. The surface normal points in the direction of dv x du, so the
+// parametrization decides which side is visible
+func addSurface(mesh *Mesh, uSegments, vSegments int, vertexAt func(i, j int) (Vector3d, VectorUv, Vector3d)) {
+	for j := 0; j < vSegments; j++ {
+		for i := 0; i < uSegments; i++ {
+			p00, uv00, n00 := vertexAt(i, j)
+			p10, uv10, n10 := vertexAt(i+1, j)
+			p01, uv01, n01 := vertexAt(i, j+1)
+			p11, uv11, n11 := vertexAt(i+1, j+1)
+
+			addPrimitiveTriangle(mesh,
+				[3]Vector3d{p00, p01, p11},
+				[3]VectorUv{uv00, uv01, uv11},
+				[3]Vector3d{n00, n01, n11})
+			addPrimitiveTriangle(mesh,
+				[3]Vector3d{p00, p11, p10},
+				[3]VectorUv{uv00, uv11, uv10},
+				[3]Vector3d{n00, n11, n10})
+		}
+	}
+}
+
+// addGrid adds a flat rectangle starting at `origin` and spanning `uAxis` and `vAxis`
+func addGrid(mesh *Mesh, origin, uAxis, vAxis Vector3d, uSegments, vSegments int) {
+	normal := vAxis.Cross(&uAxis)
+	normal.Normalize()
+	normal.W = 0
+
+	addSurface(mesh, uSegments, vSegments, func(i, j int) (Vector3d, VectorUv, Vector3d) {
+		u := float64(i) / float64(uSegments)
+		v := float64(j) / float64(vSegments)
+		du := uAxis.Mul(u)
+		dv := vAxis.Mul(v)
+		position := origin.Add(&du)
+		position = position.Add(&dv)
+		position.W = 1
+		return position, VectorUv{U: u, V: 1 - v}, normal
+	})
+}
+
+// addRevolution revolves a profile, ordered from bottom to top, around the Y axis
+func addRevolution(mesh *Mesh, profile []profilePoint, segments int) {
+	addSurface(mesh, segments, len(profile)-1, func(i, j int) (Vector3d, VectorUv, Vector3d) {
+		u := float64(i) / float64(segments)
+		angle := u * 2 * math.Pi
+		sin, cos := math.Sincos(angle)
+		p := profile[j]
+		position := Vector3d{X: p.radius * cos, Y: p.y, Z: p.radius * sin, W: 1}
+		normal := Vector3d{X: p.normalRadius * cos, Y: p.normalY, Z: p.normalRadius * sin}
+		return position, VectorUv{U: u, V: 1 - p.v}, normal
+	})
+}
+
+// addDisk adds a flat disk at height `y`, facing up or down
+func addDisk(mesh *Mesh, radius, y float64, segments int, up bool) {
+	center := Vector3d{X: 0, Y: y, Z: 0, W: 1}
+	centerUv := VectorUv{U: 0.5, V: 0.5}
+	normal := Vector3d{Y: -1}
+	if up {
+		normal.Y = 1
+	}
+
+	for i := 0; i < segments; i++ {
+		sin1, cos1 := math.Sincos(float64(i) / float64(segments) * 2 * math.Pi)
+		sin2, cos2 := math.Sincos(float64(i+1) / float64(segments) * 2 * math.Pi)
+		p1 := Vector3d{X: radius * cos1, Y: y, Z: radius * sin1, W: 1}
+		p2 := Vector3d{X: radius * cos2, Y: y, Z: radius * sin2, W: 1}
+		uv1 := VectorUv{U: 0.5 + cos1/2, V: 0.5 - sin1/2}
+		uv2 := VectorUv{U: 0.5 + cos2/2, V: 0.5 - sin2/2}
+
+		if up {
+			addPrimitiveTriangle(mesh, [3]Vector3d{center, p2, p1}, [3]VectorUv{centerUv, uv2, uv1}, [3]Vector3d{normal, normal, normal})
+		} else {
+			addPrimitiveTriangle(mesh, [3]Vector3d{center, p1, p2}, [3]VectorUv{centerUv, uv1, uv2}, [3]Vector3d{normal, normal, normal})
+		}
+	}
+}
+
+// Plane returns a flat grid in the XZ plane facing up (+Y)
+func Plane(width, depth float64, segmentsX, segmentsZ int) *Mesh {
+	segmentsX = max(segmentsX, 1)
+	segmentsZ = max(segmentsZ, 1)
+
+	mesh := NewMesh()
+	addGrid(mesh,
+		Vector3d{X: -width / 2, Y: 0, Z: -depth / 2},
+		Vector3d{X: width},
+		Vector3d{Z: depth},
+		segmentsX, segmentsZ)
+	return mesh
+}
+
+// Box returns a box with arbitrary dimensions. Every side maps the whole texture
+func Box(width, height, depth float64, segmentsX, segmentsY, segmentsZ int) *Mesh {
+	segmentsX = max(segmentsX, 1)
+	segmentsY = max(segmentsY, 1)
+	segmentsZ = max(segmentsZ, 1)
+	x, y, z := width/2, height/2, depth/2
+
+	mesh := NewMesh()
+	// Front (-Z) and back (+Z)
+	addGrid(mesh, Vector3d{X: -x, Y: -y, Z: -z}, Vector3d{X: width}, Vector3d{Y: height}, segmentsX, segmentsY)
+	addGrid(mesh, Vector3d{X: x, Y: -y, Z: z}, Vector3d{X: -width}, Vector3d{Y: height}, segmentsX, segmentsY)
+	// Left (-X) and right (+X)
+	addGrid(mesh, Vector3d{X: -x, Y: -y, Z: z}, Vector3d{Z: -depth}, Vector3d{Y: height}, segmentsZ, segmentsY)
+	addGrid(mesh, Vector3d{X: x, Y: -y, Z: -z}, Vector3d{Z: depth}, Vector3d{Y: height}, segmentsZ, segmentsY)
+	// Top (+Y) and bottom (-Y)
+	addGrid(mesh, Vector3d{X: -x, Y: y, Z: -z}, Vector3d{X: width}, Vector3d{Z: depth}, segmentsX, segmentsZ)
+	addGrid(mesh, Vector3d{X: -x, Y: -y, Z: z}, Vector3d{X: width}, Vector3d{Z: -depth}, segmentsX, segmentsZ)
+	return mesh
+}
+
+// UVSphere returns a sphere made of `segments` meridians and `rings` parallels
+func UVSphere(radius float64, segments, rings int) *Mesh {
+	segments = max(segments, 3)
+	rings = max(rings, 2)
+
+	profile := make([]profilePoint, rings+1)
+	for j := range profile {
+		v := float64(j) / float64(rings)
+		sin, cos := math.Sincos(v*math.Pi - math.Pi/2)
+		profile[j] = profilePoint{radius: radius * cos, y: radius * sin, normalRadius: cos, normalY: sin, v: v}
+	}
+
+	mesh := NewMesh()
+	addRevolution(mesh, profile, segments)
+	return mesh
+}
+
+// Icosphere returns a sphere made of evenly sized triangles by subdividing an
+// icosahedron. Every subdivision quadruples the number of triangles
+func Icosphere(radius float64, subdivisions int) *Mesh {
+	subdivisions = max(subdivisions, 0)
+
+	t := (1 + math.Sqrt(5)) / 2
+	vertices := []Vector3d{
+		{X: -1, Y: t}, {X: 1, Y: t}, {X: -1, Y: -t}, {X: 1, Y: -t},
+		{Y: -1, Z: t}, {Y: 1, Z: t}, {Y: -1, Z: -t}, {Y: 1, Z: -t},
+		{X: t, Z: -1}, {X: t, Z: 1}, {X: -t, Z: -1}, {X: -t, Z: 1},
+	}
+	for i := range vertices {
+		vertices[i].Normalize()
+	}
+
+	faces := [][3]int{
+		{0, 11, 5}, {0, 5, 1}, {0, 1, 7}, {0, 7, 10}, {0, 10, 11},
+		{1, 5, 9}, {5, 11, 4}, {11, 10, 2}, {10, 7, 6}, {7, 1, 8},
+		{3, 9, 4}, {3, 4, 2}, {3, 2, 6}, {3, 6, 8}, {3, 8, 9},
+		{4, 9, 5}, {2, 4, 11}, {6, 2, 10}, {8, 6, 7}, {9, 8, 1},
+	}
+
+	for s := 0; s < subdivisions; s++ {
+		midpoints := map[[2]int]int{}
+		midpoint := func(a, b int) int {
+			key := [2]int{min(a, b), max(a, b)}
+			if index, ok := midpoints[key]; ok {
+				return index
+			}
+			m := vertices[a].Add(&vertices[b])
+			m.Normalize()
+			vertices = append(vertices, m)
+			midpoints[key] = len(vertices) - 1
+			return len(vertices) - 1
+		}
+
+		subdivided := make([][3]int, 0, len(faces)*4)
+		for _, f := range faces {
+			a := midpoint(f[0], f[1])
+			b := midpoint(f[1], f[2])
+			c := midpoint(f[2], f[0])
+			subdivided = append(subdivided,
+				[3]int{f[0], a, c}, [3]int{f[1], b, a}, [3]int{f[2], c, b}, [3]int{a, b, c})
+		}
+		faces = subdivided
+	}
+
+	mesh := NewMesh()
+	for _, f := range faces {
+		triangle := [3]Vector3d{vertices[f[0]], vertices[f[1]], vertices[f[2]]}
+
+		// Make sure the triangle faces outwards
+		l1 := triangle[1].Sub(&triangle[0])
+		l2 := triangle[2].Sub(&triangle[0])
+		cross := l1.Cross(&l2)
+		if cross.Dot(&triangle[0]) < 0 {
+			triangle[1], triangle[2] = triangle[2], triangle[1]
+		}
+
+		var positions, normals [3]Vector3d
+		var uvs [3]VectorUv
+		for i, n := range triangle {
+			normals[i] = Vector3d{X: n.X, Y: n.Y, Z: n.Z}
+			positions[i] = Vector3d{X: n.X * radius, Y: n.Y * radius, Z: n.Z * radius, W: 1}
+			uvs[i] = VectorUv{
+				U: 0.5 + math.Atan2(n.Z, n.X)/(2*math.Pi),
+				V: 0.5 - math.Asin(math.Max(-1, math.Min(1, n.Y)))/math.Pi,
+			}
+		}
+
+		// Triangles crossing the seam of the texture would otherwise map (almost)
+		// the whole texture, shift their coordinates past 1 instead
+		if math.Max(uvs[0].U, math.Max(uvs[1].U, uvs[2].U))-math.Min(uvs[0].U, math.Min(uvs[1].U, uvs[2].U)) > 0.5 {
+			for i := range uvs {
+				if uvs[i].U < 0.5 {
+					uvs[i].U += 1
+				}
+			}
+		}
+
+		addPrimitiveTriangle(mesh, positions, uvs, normals)
+	}
+	return mesh
+}
+
+// Cylinder returns a closed cylinder along the Y axis
+func Cylinder(radius, height float64, segments, heightSegments int) *Mesh {
+	segments = max(segments, 3)
+	heightSegments = max(heightSegments, 1)
+
+	profile := make([]profilePoint, heightSegments+1)
+	for j := range profile {
+		v := float64(j) / float64(heightSegments)
+		profile[j] = profilePoint{radius: radius, y: -height/2 + v*height, normalRadius: 1, v: v}
+	}
+
+	mesh := NewMesh()
+	addRevolution(mesh, profile, segments)
+	addDisk(mesh, radius, -height/2, segments, false)
+	addDisk(mesh, radius, height/2, segments, true)
+	return mesh
+}
+
+// Cone returns a closed cone along the Y axis with the tip pointing up
+func Cone(radius, height float64, segments, heightSegments int) *Mesh {
+	segments = max(segments, 3)
+	heightSegments = max(heightSegments, 1)
+
+	// The side normal is perpendicular to the slope
+	slope := math.Hypot(radius, height)
+	normalRadius, normalY := height/slope, radius/slope
+
+	profile := make([]profilePoint, heightSegments+1)
+	for j := range profile {
+		v := float64(j) / float64(heightSegments)
+		profile[j] = profilePoint{radius: radius * (1 - v), y: -height/2 + v*height, normalRadius: normalRadius, normalY: normalY, v: v}
+	}
+
+	mesh := NewMesh()
+	addRevolution(mesh, profile, segments)
+	addDisk(mesh, radius, -height/2, segments, false)
+	return mesh
+}
+
+// Torus returns a torus lying in the XZ plane. `majorRadius` is the distance from
+// the center to the center of the tube, `minorRadius` the radius of the tube
+func Torus(majorRadius, minorRadius float64, majorSegments, minorSegments int) *Mesh {
+	majorSegments = max(majorSegments, 3)
+	minorSegments = max(minorSegments, 3)
+
+	// Start at the inner side so the texture seam faces the hole
+	profile := make([]profilePoint, minorSegments+1)
+	for j := range profile {
+		v := float64(j) / float64(minorSegments)
+		sin, cos := math.Sincos(v*2*math.Pi - math.Pi)
+		profile[j] = profilePoint{radius: majorRadius + minorRadius*cos, y: minorRadius * sin, normalRadius: cos, normalY: sin, v: v}
+	}
+
+	mesh := NewMesh()
+	addRevolution(mesh, profile, majorSegments)
+	return mesh
+}
+
+// Capsule returns a cylinder along the Y axis with hemispherical caps. `height` is the
+// length of the cylindrical part, `rings` the number of parallels per hemisphere
+func Capsule(radius, height float64, segments, rings int) *Mesh {
+	segments = max(segments, 3)
+	rings = max(rings, 1)
+
+	// Texture coordinates are distributed by arc length along the profile
+	arc := math.Pi / 2 * radius
+	total := 2*arc + height
+
+	profile := make([]profilePoint, 0, 2*rings+2)
+	for j := 0; j <= rings; j++ {
+		angle := float64(j) / float64(rings) * math.Pi / 2
+		sin, cos := math.Sincos(angle - math.Pi/2)
+		profile = append(profile, profilePoint{
+			radius: radius * cos, y: -height/2 + radius*sin,
+			normalRadius: cos, normalY: sin,
+			v: angle * radius / total,
+		})
+	}
+	for j := 0; j <= rings; j++ {
+		angle := float64(j) / float64(rings) * math.Pi / 2
+		sin, cos := math.Sincos(angle)
+		profile = append(profile, profilePoint{
+			radius: radius * cos, y: height/2 + radius*sin,
+			normalRadius: cos, normalY: sin,
+			v: (arc + height + angle*radius) / total,
+		})
+	}
+
+	mesh := NewMesh()
+	addRevolution(mesh, profile, segments)
+	return mesh
+}
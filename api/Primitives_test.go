@@ -0,0 +1,58 @@
+package api
+
+import (
+	"math"
+	"testing"
+)
+
+// checkOutwards verifies that every triangle of the mesh faces away from the
+// point returned by `inside`
+func checkOutwards(t *testing.T, name string, mesh *Mesh, inside func(v Vector3d) Vector3d) {
+	if len(mesh.triangles) == 0 {
+		t.Fatalf("%s: no triangles", name)
+	}
+
+	for i, triangle := range mesh.triangles {
+		centroid := triangle.Vertices[0].Add(&triangle.Vertices[1])
+		centroid = centroid.Add(&triangle.Vertices[2])
+		centroid = centroid.Div(3)
+		reference := inside(centroid)
+		outwards := centroid.Sub(&reference)
+
+		normal := triangle.Normal()
+		if normal.Dot(&outwards) <= 0 {
+			t.Fatalf("%s: triangle %d faces inwards", name, i)
+		}
+		for v := range triangle.Normals {
+			if normal.Dot(&triangle.Normals[v]) <= 0 {
+				t.Fatalf("%s: triangle %d has a vertex normal opposing its face", name, i)
+			}
+		}
+	}
+}
+
+func TestPrimitives_Winding(t *testing.T) {
+	origin := func(v Vector3d) Vector3d { return Vector3d{} }
+
+	checkOutwards(t, "Plane", Plane(2, 2, 3, 3), func(v Vector3d) Vector3d { return Vector3d{X: v.X, Y: -1, Z: v.Z} })
+	checkOutwards(t, "Box", Box(1, 2, 3, 2, 2, 2), origin)
+	checkOutwards(t, "UVSphere", UVSphere(1, 16, 8), origin)
+	checkOutwards(t, "Icosphere", Icosphere(1, 2), origin)
+	checkOutwards(t, "Cone", Cone(1, 2, 16, 2), origin)
+	checkOutwards(t, "Cylinder", Cylinder(1, 2, 16, 2), func(v Vector3d) Vector3d {
+		// Sides face away from the axis, caps away from the center
+		if math.Abs(math.Abs(v.Y)-1) < 1e-9 {
+			return Vector3d{}
+		}
+		return Vector3d{Y: v.Y}
+	})
+	checkOutwards(t, "Capsule", Capsule(0.5, 1, 16, 4), func(v Vector3d) Vector3d {
+		return Vector3d{Y: math.Max(-0.5, math.Min(0.5, v.Y))}
+	})
+	checkOutwards(t, "Torus", Torus(2, 0.5, 24, 12), func(v Vector3d) Vector3d {
+		// Closest point on the center line of the tube
+		center := Vector3d{X: v.X, Z: v.Z}
+		center.Normalize()
+		return center.Mul(2)
+	})
+}
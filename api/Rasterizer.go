@@ -0,0 +1,450 @@
+package api
+
+import (
+	"image/color"
+	"math"
+)
+
+// Rasterizer selects the algorithm that converts triangles into pixels
+type Rasterizer int
+
+const (
+	// RasterizerScanline walks the triangle row by row between its edges. Vertex
+	// positions are truncated to whole pixels
+	RasterizerScanline Rasterizer = iota
+
+	// RasterizerEdgeFunction tests pixel centers against the edge functions of the
+	// triangle. Vertex positions keep `SubPixelBits` fractional bits and pixels on
+	// shared edges are assigned by the top-left rule, so adjacent triangles are
+	// watertight and every pixel is drawn once
+	RasterizerEdgeFunction
+)
+
+// rasterVertex is a projected vertex in screen coordinates
+type rasterVertex struct {
+	x, y       int
+	attributes *Attributes
+}
+
+// rasterTriangle holds the state shared by all pixels of a triangle
+type rasterTriangle struct {
+	triangle     *Triangle
+	textureAtlas TextureAtlas
+	material     *Material
+	fragment     FragmentInput
+	count        int
+	vertexColors bool
+	userData     UserData
+}
+
+// attributeCount returns the number of attributes the rasterizer has to interpolate
+// for a triangle, unused slots at the end of the vector are skipped
+func (t *Triangle) attributeCount(material *Material) int {
+	if material != nil || t.receiveShadows {
+		return MaxAttributes
+	}
+	if t.colorAttributes {
+		return AttributeA + 1
+	}
+	if t.Color != nil {
+		return AttributeW + 1
+	}
+	return AttributeV + 1
+}
+
+// sampleTexture returns the texel at the given texture coordinates
+func (e *Engine) sampleTexture(textureAtlas TextureAtlas, u, v float64) color.Color {
+	textureX := int(u * float64(textureAtlas.W()-1))
+	textureY := 0
+	if e.yOrigin == YOriginUpperLeft {
+		textureY = int(v * float64(textureAtlas.H()-1))
+	} else {
+		textureY = int((1 - v) * float64(textureAtlas.H()-1)) // invert Y to conform with blender origin
+	}
+	return textureAtlas.ColorAt(textureX, textureY)
+}
+
+// drawTriangle draw all pixels of a projected triangle. Supports textured, colored
+// and shaded triangles. The attributes of the triangle are interpolated with
+// perspective correction, pixels outside the screen are skipped
+func (e *Engine) drawTriangle(triangle *Triangle, material *Material, uniforms *Uniforms, userData UserData) {
+	// The state lives in the engine, the fragment stage gets a pointer into it
+	r := &e.raster
+	*r = rasterTriangle{
+		triangle: triangle,
+		material: material,
+		count:    triangle.attributeCount(material),
+		userData: userData,
+	}
+	r.vertexColors = triangle.colorAttributes
+	r.fragment.Uniforms = uniforms
+
+	// Triangles can bring their own texture, otherwise fall back to the atlas
+	r.textureAtlas = triangle.Texture
+	if r.textureAtlas == nil {
+		r.textureAtlas = e.textureAtlas
+	}
+
+	// Height fog needs the world position of every pixel, shadow maps only the
+	// depth
+	if e.fog.heightFog() {
+		r.count = MaxAttributes
+	}
+	if e.depthOnly {
+		r.count = AttributeW + 1
+	}
+
+	if e.samples != nil && e.samples.multisample {
+		e.samples.nextTriangle()
+	}
+
+	if e.rasterizer == RasterizerEdgeFunction {
+		e.rasterizeEdgeFunction(r)
+	} else {
+		e.rasterizeScanline(r)
+	}
+}
+
+// rasterizeScanline draws a triangle row by row. The long edge spans the whole
+// triangle, the short side switches at the middle vertex
+func (e *Engine) rasterizeScanline(r *rasterTriangle) {
+	var vertices [3]rasterVertex
+	for i := range vertices {
+		vertices[i].x, vertices[i].y, _, _, _ = r.triangle.UnpackVertex(i)
+		vertices[i].attributes = &r.triangle.Attributes[i]
+	}
+	v1, v2, v3 := &vertices[0], &vertices[1], &vertices[2]
+
+	// Presort points by their y coordinate
+	if v2.y < v1.y {
+		v1, v2 = v2, v1
+	}
+	if v3.y < v1.y {
+		v1, v3 = v3, v1
+	}
+	if v3.y < v2.y {
+		v2, v3 = v3, v2
+	}
+
+	count := r.count
+	var start, end, current Attributes
+
+	// edge returns the x coordinate and attributes at row y of the edge from a to b
+	edge := func(a, b *rasterVertex, y int, attributes *Attributes) float64 {
+		if b.y == a.y {
+			*attributes = *a.attributes
+			return float64(a.x)
+		}
+		t := float64(y-a.y) / float64(b.y-a.y)
+		for k := 0; k < count; k++ {
+			attributes[k] = a.attributes[k] + t*(b.attributes[k]-a.attributes[k])
+		}
+		return float64(a.x) + t*float64(b.x-a.x)
+	}
+
+	for y := max(v1.y, 0); y <= min(v3.y, e.h-1); y++ {
+		bx := edge(v1, v3, y, &end)
+		ax := 0.0
+		if y < v2.y {
+			ax = edge(v1, v2, y, &start)
+		} else {
+			ax = edge(v2, v3, y, &start)
+		}
+		if ax > bx {
+			ax, bx = bx, ax
+			start, end = end, start
+		}
+		if bx <= ax {
+			continue
+		}
+
+		for x := max(int(ax), 0); x < min(int(math.Ceil(bx)), e.w); x++ {
+			t := math.Max(0, math.Min(1, (float64(x)-ax)/(bx-ax)))
+			for k := 0; k < count; k++ {
+				current[k] = (1-t)*start[k] + t*end[k]
+			}
+			e.shadePixel(r, x, y, &current)
+		}
+		e.flushSpan(r.userData)
+	}
+}
+
+// rasterizeEdgeFunction draws a triangle by evaluating its edge functions at the
+// center of every pixel of its bounding box. Coordinates are fixed point with
+// `subPixelBits` fractional bits, so the tests are exact
+func (e *Engine) rasterizeEdgeFunction(r *rasterTriangle) {
+	one := int64(1) << e.subPixelBits
+	half := one >> 1
+
+	var x, y [3]int64
+	attributes := [3]*Attributes{}
+	for i := range x {
+		x[i] = int64(math.Round(r.triangle.Vertices[i].X * float64(one)))
+		y[i] = int64(math.Round(r.triangle.Vertices[i].Y * float64(one)))
+		attributes[i] = &r.triangle.Attributes[i]
+	}
+
+	// orient returns twice the signed area of the triangle a, b, p. It is positive
+	// if p lies right of the edge from a to b (y points down)
+	orient := func(ax, ay, bx, by, px, py int64) int64 {
+		return (bx-ax)*(py-ay) - (by-ay)*(px-ax)
+	}
+
+	area := orient(x[0], y[0], x[1], y[1], x[2], y[2])
+	if area == 0 {
+		return
+	}
+
+	// Make the winding consistent, so that inside means all edge functions are positive
+	if area < 0 {
+		x[1], x[2] = x[2], x[1]
+		y[1], y[2] = y[2], y[1]
+		attributes[1], attributes[2] = attributes[2], attributes[1]
+		area = -area
+	}
+
+	// Bounding box in pixels, clamped to the screen
+	minX := max(int((min(x[0], x[1], x[2])-half)>>e.subPixelBits), 0)
+	maxX := min(int((max(x[0], x[1], x[2])+half)>>e.subPixelBits), e.w-1)
+	minY := max(int((min(y[0], y[1], y[2])-half)>>e.subPixelBits), 0)
+	maxY := min(int((max(y[0], y[1], y[2])+half)>>e.subPixelBits), e.h-1)
+	if minX > maxX || minY > maxY {
+		return
+	}
+
+	// Edge i lies opposite of vertex i. Pixel centers exactly on an edge only
+	// belong to the triangle if it is a top or left edge, the bias excludes all
+	// others
+	var stepX, stepY, row [3]int64
+	centerX := int64(minX)*one + half
+	centerY := int64(minY)*one + half
+	for i := range row {
+		a, b := (i+1)%3, (i+2)%3
+		dx, dy := x[b]-x[a], y[b]-y[a]
+		stepX[i] = -dy * one
+		stepY[i] = dx * one
+		row[i] = orient(x[a], y[a], x[b], y[b], centerX, centerY)
+
+		top := dy == 0 && dx > 0
+		left := dy < 0
+		if !top && !left {
+			row[i]--
+		}
+	}
+
+	invArea := 1 / float64(area)
+	count := r.count
+	var current Attributes
+
+	for py := minY; py <= maxY; py++ {
+		w := row
+		for px := minX; px <= maxX; px++ {
+			// The sign bit is set if any of the edge functions is negative
+			if w[0]|w[1]|w[2] >= 0 {
+				// Barycentric weights, the bias of excluded edges is negligible
+				l0 := float64(w[0]) * invArea
+				l1 := float64(w[1]) * invArea
+				l2 := 1 - l0 - l1
+				for k := 0; k < count; k++ {
+					current[k] = l0*attributes[0][k] + l1*attributes[1][k] + l2*attributes[2][k]
+				}
+				e.shadePixel(r, px, py, &current)
+			}
+			w[0] += stepX[0]
+			w[1] += stepX[1]
+			w[2] += stepX[2]
+		}
+		e.flushSpan(r.userData)
+		row[0] += stepY[0]
+		row[1] += stepY[1]
+		row[2] += stepY[2]
+	}
+}
+
+// shadePixel depth tests a pixel with interpolated attributes and draws it in the
+// color of the triangle, its vertices, its texture or the fragment stage. Without
+// fragment stage, pixels in shadow are darkened. Fog is applied last
+func (e *Engine) shadePixel(r *rasterTriangle, x, y int, current *Attributes) {
+	depth := current[AttributeW]
+	if e.depthOnly {
+		if depth > e.depthBuffer.At(x, y) {
+			e.depthBuffer.Set(x, y, depth)
+		}
+		return
+	}
+
+	// Painter's sorting neither tests nor writes the depth buffer
+	useDepth := !e.retro.painterSort()
+	if useDepth && depth <= e.depthBuffer.At(x, y) {
+		e.Metrics.PixelsRejected++
+		return
+	}
+
+	if e.debugMode != DebugNone && e.debugShade(r, x, y) {
+		if useDepth {
+			e.depthBuffer.Set(x, y, depth)
+		}
+		e.Metrics.PixelsWritten++
+		return
+	}
+
+	// Multisampling shades a triangle once per pixel, further samples of the
+	// pixel reuse the color
+	multisample := e.samples != nil && e.samples.multisample
+	if multisample {
+		if c, ok := e.cachedShade(x, y); ok {
+			e.plotRGBA(x, y, c, r.userData)
+			if useDepth {
+				e.depthBuffer.Set(x, y, depth)
+			}
+			e.Metrics.PixelsWritten++
+			return
+		}
+	}
+
+	// Undo the perspective divide, affine attributes were not divided
+	divisor := depth
+	if e.retro.affine() {
+		divisor = 1
+	}
+	u := current[AttributeU] / divisor
+	v := current[AttributeV] / divisor
+
+	light := 1.0
+	if r.triangle.receiveShadows {
+		light = e.shadowLight(current, 1/divisor)
+	}
+
+	// Vertex colors, shadowed and fogged colors stay a concrete value until they
+	// are needed as interface
+	var c color.Color
+	var rgba color.RGBA64
+	useRGBA := r.vertexColors
+	if useRGBA {
+		rgba = attributeColor(current, 1/divisor)
+	} else if r.triangle.Color != nil {
+		c = r.triangle.Color
+	} else if r.textureAtlas != nil {
+		c = e.sampleTexture(r.textureAtlas, u, v)
+	}
+
+	if r.material != nil && r.material.FragmentShader != nil {
+		fragment := &r.fragment
+		fragment.X = x
+		fragment.Y = y
+		fragment.Depth = depth
+		fragment.UV = VectorUv{U: u, V: v, W: depth}
+		for k := range fragment.Varyings {
+			fragment.Varyings[k] = current[AttributeVaryings+k] / divisor
+		}
+		fragment.Color = c
+		if useRGBA {
+			fragment.Color = rgba
+		}
+		fragment.Light = light
+
+		var keep bool
+		c, keep = r.material.FragmentShader(fragment)
+		if !keep {
+			return
+		}
+		useRGBA = false
+	} else if light < 1 && (useRGBA || c != nil) {
+		if !useRGBA {
+			rgba, useRGBA = toRGBA64(c), true
+		}
+		rgba = shadowColor(rgba, light)
+	}
+
+	if e.fog != nil && (useRGBA || c != nil) {
+		if !useRGBA {
+			rgba, useRGBA = toRGBA64(c), true
+		}
+		height := 0.0
+		if e.fog.heightFog() {
+			height = current[AttributeWorldY] / divisor
+		}
+		rgba = e.fog.apply(rgba, 1/depth, height)
+	}
+
+	if useRGBA {
+		if e.drawSpan != nil {
+			e.plotRGBA(x, y, color.RGBA{R: uint8(rgba.R >> 8), G: uint8(rgba.G >> 8), B: uint8(rgba.B >> 8), A: uint8(rgba.A >> 8)}, r.userData)
+		} else {
+			e.drawPixel(e.offsetX+x, e.offsetY+y, rgba, r.userData)
+		}
+	} else {
+		if c == nil {
+			panic("draw error: neither textureAtlas nor color defined")
+		}
+		e.plot(x, y, c, r.userData)
+	}
+
+	// With anti-aliasing pixels always end up in the span buffer
+	if multisample {
+		e.cacheShade(x, y, e.spanBuffer[len(e.spanBuffer)-1])
+	}
+	if useDepth {
+		e.depthBuffer.Set(x, y, depth)
+	}
+	e.Metrics.PixelsWritten++
+}
+
+// toRGBA64 converts a color into a concrete value
+func toRGBA64(c color.Color) color.RGBA64 {
+	r, g, b, a := c.RGBA()
+	return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+}
+
+// toRGBA converts a color into 8 bits per channel
+func toRGBA(c color.Color) color.RGBA {
+	if rgba, ok := c.(color.RGBA); ok {
+		return rgba
+	}
+	r, g, b, a := c.RGBA()
+	return color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}
+}
+
+// plot outputs a single pixel, either through the draw hook or as part of the
+// current span
+func (e *Engine) plot(x, y int, c color.Color, userData UserData) {
+	if e.drawSpan == nil {
+		e.drawPixel(e.offsetX+x, e.offsetY+y, c, userData)
+		return
+	}
+
+	e.plotRGBA(x, y, toRGBA(c), userData)
+}
+
+// plotColor outputs a single pixel of a concrete color
+func (e *Engine) plotColor(x, y int, c color.RGBA, userData UserData) {
+	if e.drawSpan != nil {
+		e.plotRGBA(x, y, c, userData)
+	} else {
+		e.drawPixel(e.offsetX+x, e.offsetY+y, c, userData)
+	}
+}
+
+// plotRGBA appends a pixel to the current span. A pixel that does not continue
+// the span ends it
+func (e *Engine) plotRGBA(x, y int, c color.RGBA, userData UserData) {
+	if len(e.spanBuffer) > 0 && (y != e.spanY || x != e.spanX+len(e.spanBuffer)) {
+		e.flushSpan(userData)
+	}
+	if len(e.spanBuffer) == 0 {
+		e.spanX = x
+		e.spanY = y
+	}
+	e.spanBuffer = append(e.spanBuffer, c)
+}
+
+// flushSpan hands the current span to the span hook. Spans are collected in the
+// coordinates of the viewport and moved to its position in the target
+func (e *Engine) flushSpan(userData UserData) {
+	if len(e.spanBuffer) == 0 {
+		return
+	}
+	x := e.offsetX + e.spanX
+	e.drawSpan(e.offsetY+e.spanY, x, x+len(e.spanBuffer), e.spanBuffer, userData)
+	e.spanBuffer = e.spanBuffer[:0]
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestRasterizer_EdgeFunctionWatertight(t *testing.T) {
+	coverage := map[[2]int]int{}
+	engine := NewEngine(32, 32, 90, func(x, y int, c color.Color, userData UserData) {
+		coverage[[2]int{x, y}]++
+	}, &EngineOptions{Rasterizer: RasterizerEdgeFunction})
+
+	// A fan around a center with fractional coordinates, drawn with both windings
+	center := Vector3d{X: 16.3, Y: 15.7, W: 1}
+	segments := 7
+	radius := 12.0
+	for i := 0; i < segments; i++ {
+		a := 2 * math.Pi * float64(i) / float64(segments)
+		b := 2 * math.Pi * float64(i+1) / float64(segments)
+		triangle := Triangle{
+			Vertices: [3]Vector3d{
+				center,
+				{X: center.X + radius*math.Cos(a), Y: center.Y + radius*math.Sin(a), W: 1},
+				{X: center.X + radius*math.Cos(b), Y: center.Y + radius*math.Sin(b), W: 1},
+			},
+			Color: color.White,
+		}
+		if i%2 == 1 {
+			triangle.Vertices[1], triangle.Vertices[2] = triangle.Vertices[2], triangle.Vertices[1]
+		}
+		for v := range triangle.Attributes {
+			triangle.Attributes[v][AttributeW] = 1
+		}
+
+		// Without depth test, every pixel drawn twice would show up
+		engine.depthBuffer.Clear()
+		engine.drawTriangle(&triangle, nil, nil, nil)
+	}
+
+	// The inner radius of the polygon is radius * cos(pi / segments)
+	inner := radius * math.Cos(math.Pi/float64(segments))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			count := coverage[[2]int{x, y}]
+			if count > 1 {
+				t.Fatalf("pixel %d/%d drawn %d times", x, y, count)
+			}
+			distance := math.Hypot(float64(x)+0.5-center.X, float64(y)+0.5-center.Y)
+			if distance < inner-0.5 && count == 0 {
+				t.Fatalf("crack at pixel %d/%d", x, y)
+			}
+		}
+	}
+}
+
+func TestRasterizer_EdgeFunctionCoverage(t *testing.T) {
+	scanline, _ := renderCoverage(ColoredCube())
+
+	pixels := 0
+	engine := NewEngine(64, 64, 90, func(x, y int, c color.Color, userData UserData) {
+		pixels++
+	}, &EngineOptions{Rasterizer: RasterizerEdgeFunction, SubPixelBits: 8})
+	engine.AddMesh(ColoredCube())
+	engine.SetCameraPositionAbsolute(0.5, 0.5, -2, 0.3, 0.2)
+	engine.Render(nil)
+
+	if math.Abs(float64(pixels-scanline)) > 0.1*float64(scanline) {
+		t.Fatalf("expected about %d pixels, got %d", scanline, pixels)
+	}
+}
+
+func TestRasterizer_SpanHook(t *testing.T) {
+	for _, rasterizer := range []Rasterizer{RasterizerScanline, RasterizerEdgeFunction} {
+		expected := map[[2]int]color.RGBA{}
+		engine := NewEngine(64, 64, 90, func(x, y int, c color.Color, userData UserData) {
+			expected[[2]int{x, y}] = color.RGBAModel.Convert(c).(color.RGBA)
+		}, &EngineOptions{Rasterizer: rasterizer})
+		engine.AddMesh(ColoredCube())
+		engine.SetCameraPositionAbsolute(0.5, 0.5, -2, 0.3, 0.2)
+		engine.Render(nil)
+
+		pixels := map[[2]int]color.RGBA{}
+		engine = NewEngine(64, 64, 90, nil, &EngineOptions{
+			Rasterizer: rasterizer,
+			SpanHook: func(y, x0, x1 int, colors []color.RGBA, userData UserData) {
+				if len(colors) != x1-x0 || x1 <= x0 {
+					t.Fatalf("invalid span %d..%d with %d colors", x0, x1, len(colors))
+				}
+				for x := x0; x < x1; x++ {
+					pixels[[2]int{x, y}] = colors[x-x0]
+				}
+			},
+		})
+		engine.AddMesh(ColoredCube())
+		engine.SetCameraPositionAbsolute(0.5, 0.5, -2, 0.3, 0.2)
+		engine.Render(nil)
+
+		if len(pixels) == 0 || len(pixels) != len(expected) {
+			t.Fatalf("expected %d pixels, got %d", len(expected), len(pixels))
+		}
+		for position, c := range expected {
+			if pixels[position] != c {
+				t.Fatalf("pixel %v: expected %v, got %v", position, c, pixels[position])
+			}
+		}
+	}
+}
+
+func BenchmarkEngine_DrawTriangle(b *testing.B) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	texture, err := NewImageTexture(img)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, size := range []float64{8, 64, 256} {
+		for _, textured := range []bool{false, true} {
+			name := fmt.Sprintf("colored-%d", int(size))
+			if textured {
+				name = fmt.Sprintf("textured-%d", int(size))
+			}
+
+			b.Run(name, func(b *testing.B) {
+				engine := NewEngine(320, 320, 90, nil, &EngineOptions{SpanHook: noopSpanHook})
+				triangle := Triangle{
+					Vertices: [3]Vector3d{
+						{X: 10, Y: 10, W: 1},
+						{X: 10 + size, Y: 10 + size/2, W: 1},
+						{X: 10, Y: 10 + size, W: 1},
+					},
+					UVs: UVs{{U: 0, V: 0}, {U: 1, V: 0.5}, {U: 0, V: 1}},
+				}
+				if textured {
+					triangle.Texture = texture
+				} else {
+					triangle.Color = color.RGBA{R: 255, A: 255}
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					// Every iteration is a bit closer, so no pixel fails the depth test
+					depth := 1 + float64(i)*1e-9
+					for v := range triangle.Attributes {
+						triangle.Attributes[v] = vertexAttributes(&triangle, v)
+						triangle.Attributes[v][AttributeW] = depth
+						triangle.Attributes[v][AttributeU] *= depth
+						triangle.Attributes[v][AttributeV] *= depth
+					}
+					engine.drawTriangle(&triangle, nil, nil, nil)
+				}
+			})
+		}
+	}
+}
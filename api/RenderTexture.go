@@ -0,0 +1,141 @@
+package api
+
+import (
+	"image/color"
+	"slices"
+)
+
+// renderPass identifies a call to `Render`. Render textures are rendered at most
+// once per pass, no matter how many engines use them
+type renderPass struct {
+	engine *Engine
+	frame  uint64
+}
+
+// RenderTexture is an off-screen framebuffer with its own engine. It implements
+// `TextureAtlas`, so triangles of another engine can show the view of a second
+// camera, e.g. for monitors, mirrors and portals. Engines render the textures
+// their triangles use before their own frame
+type RenderTexture struct {
+	// Engine renders into the texture. Its camera and meshes are set up like for
+	// any other engine, meshes can be shared between engines
+	Engine *Engine
+
+	// The last rendered frame and the frame being rendered
+	pixels []color.RGBA
+	back   []color.RGBA
+	w, h   int
+
+	// The pass the texture was last rendered in and whether it is rendered right
+	// now
+	pass      renderPass
+	rendering bool
+}
+
+// NewRenderTexture creates a render texture of the given size. The options are
+// passed to its engine, except for the span and palette hooks
+func NewRenderTexture(w, h int, fovDegrees float64, opts *EngineOptions) *RenderTexture {
+	texture := &RenderTexture{
+		pixels: make([]color.RGBA, w*h),
+		back:   make([]color.RGBA, w*h),
+		w:      w,
+		h:      h,
+	}
+
+	textureOpts := EngineOptions{}
+	if opts != nil {
+		textureOpts = *opts
+	}
+	textureOpts.SpanHook = texture.drawSpan
+	textureOpts.PaletteHook = nil
+	texture.Engine = NewEngine(w, h, fovDegrees, nil, &textureOpts)
+	return texture
+}
+
+func (t *RenderTexture) W() int {
+	return t.w
+}
+
+func (t *RenderTexture) H() int {
+	return t.h
+}
+
+// ColorAt returns a pixel of the last rendered frame. Coordinates outside the
+// texture wrap around like for `ImageTexture`
+func (t *RenderTexture) ColorAt(x, y int) color.Color {
+	x %= t.w
+	if x < 0 {
+		x += t.w
+	}
+	y %= t.h
+	if y < 0 {
+		y += t.h
+	}
+	return t.pixels[y*t.w+x]
+}
+
+// Pixels returns the last rendered frame row by row. Pixels not covered by
+// geometry or a background are transparent
+func (t *RenderTexture) Pixels() []color.RGBA {
+	return t.pixels
+}
+
+// drawSpan writes the output of the engine into the frame being rendered
+func (t *RenderTexture) drawSpan(y, x0, x1 int, colors []color.RGBA, userData UserData) {
+	copy(t.back[y*t.w+x0:y*t.w+x1], colors)
+}
+
+// render renders the texture unless it was already rendered in the pass. A
+// texture that shows itself, directly or through other render textures, shows
+// its previous frame there
+func (t *RenderTexture) render(pass renderPass) {
+	if t.rendering || t.pass == pass {
+		return
+	}
+	t.rendering = true
+	t.pass = pass
+
+	clear(t.back)
+	t.Engine.render(nil, pass)
+	t.pixels, t.back = t.back, t.pixels
+
+	t.rendering = false
+}
+
+// trackTexture remembers a texture of a triangle of the mesh if it is a render
+// texture
+func (m *Mesh) trackTexture(texture TextureAtlas) {
+	if renderTexture, ok := texture.(*RenderTexture); ok && !slices.Contains(m.renderTextures, renderTexture) {
+		m.renderTextures = append(m.renderTextures, renderTexture)
+	}
+}
+
+// updateTextures collects the render textures of all triangles again after
+// their textures were replaced
+func (m *Mesh) updateTextures() {
+	m.renderTextures = m.renderTextures[:0]
+	for i := range m.triangles {
+		m.trackTexture(m.triangles[i].Texture)
+	}
+}
+
+// renderTextures renders all render textures used by the engine before they are
+// sampled. Meshes keep track of their textures, so this does not depend on the
+// number of triangles
+func (e *Engine) renderTextures(pass renderPass) {
+	if texture, ok := e.textureAtlas.(*RenderTexture); ok {
+		texture.render(pass)
+	}
+	for _, mesh := range e.meshes {
+		for _, texture := range mesh.renderTextures {
+			texture.render(pass)
+		}
+	}
+
+	// All chunks of a terrain share its texture
+	for _, terrain := range e.terrains {
+		if texture, ok := terrain.opts.Texture.(*RenderTexture); ok {
+			texture.render(pass)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"image/color"
+	"testing"
+)
+
+// monitorScene returns an engine showing a cube textured with a render texture,
+// which shows a red cube in front of a green background
+func monitorScene(pixels map[color.RGBA]int) (*Engine, *RenderTexture, *Mesh) {
+	texture := NewRenderTexture(16, 16, 90, &EngineOptions{MetricsFrames: 10})
+	cube := ColoredCube()
+	for i := range cube.triangles {
+		cube.triangles[i].Color = color.RGBA{R: 255, A: 255}
+	}
+	texture.Engine.AddMesh(cube)
+	texture.Engine.SetBackground(&SolidBackground{Color: color.RGBA{G: 255, A: 255}})
+	texture.Engine.SetCameraPositionAbsolute(0.5, 0.5, -2, 0, 0)
+
+	engine := NewEngine(32, 32, 90, func(x, y int, c color.Color, userData UserData) {
+		pixels[color.RGBAModel.Convert(c).(color.RGBA)]++
+	}, nil)
+	monitor := StandardCube()
+	monitor.SetMeshPositionRelative(-0.5, -0.5, 1)
+	for i := range monitor.triangles {
+		monitor.triangles[i].Texture = texture
+	}
+	monitor.updateTextures()
+	engine.AddMesh(monitor)
+	return engine, texture, monitor
+}
+
+func TestRenderTexture(t *testing.T) {
+	pixels := map[color.RGBA]int{}
+	engine, texture, _ := monitorScene(pixels)
+	engine.Render(nil)
+
+	if pixels[color.RGBA{R: 255, A: 255}] == 0 || pixels[color.RGBA{G: 255, A: 255}] == 0 {
+		t.Fatalf("expected the view of the texture camera, got %v", pixels)
+	}
+	if texture.Engine.Metrics.Frames() != 1 {
+		t.Fatalf("expected the texture to be rendered once, got %d", texture.Engine.Metrics.Frames())
+	}
+}
+
+func TestRenderTexture_Recursion(t *testing.T) {
+	pixels := map[color.RGBA]int{}
+	engine, texture, monitor := monitorScene(pixels)
+
+	// The texture shows the monitor, which shows the texture
+	texture.Engine.AddMesh(monitor)
+	for i := 0; i < 3; i++ {
+		engine.Render(nil)
+	}
+	if texture.Engine.Metrics.Frames() != 3 {
+		t.Fatalf("expected the texture to be rendered once per frame, got %d", texture.Engine.Metrics.Frames())
+	}
+}
+
+func TestRenderTexture_Tracking(t *testing.T) {
+	pixels := map[color.RGBA]int{}
+	engine, texture, monitor := monitorScene(pixels)
+
+	// Textured triangles added after the mesh was added to the engine are
+	// rendered as well
+	late := NewRenderTexture(4, 4, 90, nil)
+	mesh := NewMesh()
+	engine.AddMesh(mesh)
+	for _, triangle := range StandardCube().triangles {
+		triangle.Texture = late
+		mesh.AddTriangle(triangle)
+	}
+	engine.Render(nil)
+	if frames := late.Engine.Metrics.Frames(); frames != 1 {
+		t.Fatalf("expected the late texture to be rendered, got %d frames", frames)
+	}
+
+	// A texture no triangle uses any more is not rendered
+	for i := range monitor.triangles {
+		monitor.triangles[i].Texture = nil
+		monitor.triangles[i].Color = color.White
+	}
+	monitor.updateTextures()
+	engine.Render(nil)
+	if frames := texture.Engine.Metrics.Frames(); frames != 1 {
+		t.Fatalf("expected the unused texture to be skipped, got %d frames", frames)
+	}
+	if len(mesh.renderTextures) != 1 {
+		t.Fatalf("expected the texture to be tracked once, got %d", len(mesh.renderTextures))
+	}
+}
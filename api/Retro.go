@@ -0,0 +1,164 @@
+package api
+
+import (
+	"cmp"
+	"image/color"
+	"math"
+	"slices"
+	"time"
+)
+
+// Retro imitates the rendering of early 3D consoles. Every feature is enabled on
+// its own
+type Retro struct {
+	// AffineTextures interpolates texture coordinates and all other attributes
+	// linearly in screen space instead of correcting the perspective, which warps
+	// textures on triangles seen at an angle
+	AffineTextures bool
+
+	// VertexSnap snaps projected vertices to a grid of the given size in pixels,
+	// so they wobble as they move. Zero keeps the precision of the rasterizer
+	VertexSnap float64
+
+	// Dither reduces the output to 15-bit colors, or to the colors of Palette if
+	// it is set, with an ordered 4x4 Bayer dither
+	Dither  bool
+	Palette color.Palette
+
+	// PainterSort draws the triangles of every view back to front by their
+	// average depth without testing or writing the depth buffer. Intersecting
+	// triangles are not resolved, the background is drawn first and points last
+	PainterSort bool
+}
+
+// bayer4x4 is the threshold map of ordered dithering
+var bayer4x4 = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// bayerOffset returns the dither threshold of a pixel, between -0.5 and 0.5
+func bayerOffset(x, y int) float64 {
+	return (bayer4x4[y&3][x&3]+0.5)/16 - 0.5
+}
+
+// SetRetro enables the retro features, nil turns them off
+func (e *Engine) SetRetro(retro *Retro) {
+	e.retro = retro
+	if e.ditherHook == nil {
+		e.ditherHook = e.ditherSpan
+	}
+}
+
+// Retro returns the current retro features, nil if there are none
+func (e *Engine) Retro() *Retro {
+	return e.retro
+}
+
+func (r *Retro) affine() bool {
+	return r != nil && r.AffineTextures
+}
+
+func (r *Retro) painterSort() bool {
+	return r != nil && r.PainterSort
+}
+
+func (r *Retro) dithering() bool {
+	return r != nil && r.Dither
+}
+
+// snap moves the vertices of a triangle in screen space to the snapping grid
+func (r *Retro) snap(triangle *Triangle) {
+	if r == nil || r.VertexSnap <= 0 {
+		return
+	}
+	for i := range triangle.Vertices {
+		triangle.Vertices[i].X = math.Round(triangle.Vertices[i].X/r.VertexSnap) * r.VertexSnap
+		triangle.Vertices[i].Y = math.Round(triangle.Vertices[i].Y/r.VertexSnap) * r.VertexSnap
+	}
+}
+
+// dither returns the color of a pixel reduced to 15-bit or the palette. Alpha is
+// kept
+func (r *Retro) dither(x, y int, c color.RGBA) color.RGBA {
+	offset := bayerOffset(x, y)
+
+	if len(r.Palette) == 0 {
+		channel := func(v uint8) uint8 {
+			level := math.Round(float64(v)*31/255 + offset)
+			return uint8(max(0, min(31, level)) * 255 / 31)
+		}
+		return color.RGBA{R: channel(c.R), G: channel(c.G), B: channel(c.B), A: c.A}
+	}
+
+	spread := paletteSpread(len(r.Palette))
+	channel := func(v uint8) uint8 {
+		return uint8(max(0, min(255, float64(v)+offset*spread)))
+	}
+	dithered := color.RGBA{R: channel(c.R), G: channel(c.G), B: channel(c.B), A: 255}
+	matched := toRGBA(r.Palette[r.Palette.Index(dithered)])
+	matched.A = c.A
+	return matched
+}
+
+// ditherSpan replaces the hooks while dithering and hands the dithered pixels to
+// the hooks of the user
+func (e *Engine) ditherSpan(y, x0, x1 int, colors []color.RGBA, userData UserData) {
+	e.ditherBuffer = e.ditherBuffer[:0]
+	for i, c := range colors {
+		e.ditherBuffer = append(e.ditherBuffer, e.retro.dither(x0+i, y, c))
+	}
+	if e.ditherOutput != nil {
+		e.ditherOutput(y, x0, x1, e.ditherBuffer, userData)
+		return
+	}
+	for i, c := range e.ditherBuffer {
+		e.drawPixel(x0+i, y, c, userData)
+	}
+}
+
+// paintedTriangle is a triangle queued for painter's sorting
+type paintedTriangle struct {
+	triangle Triangle
+	mesh     *Mesh
+	distance float64
+}
+
+// queuePainter queues the triangles of a mesh that are ready for rasterization
+func (e *Engine) queuePainter(mesh *Mesh) {
+	for i := range e.rasterQueue {
+		triangle := &e.rasterQueue[i]
+		distance := 0.0
+		for v := range triangle.Attributes {
+			distance += 1 / triangle.Attributes[v][AttributeW]
+		}
+		e.painterQueue = append(e.painterQueue, paintedTriangle{
+			triangle: *triangle,
+			mesh:     mesh,
+			distance: distance / 3,
+		})
+	}
+}
+
+// drawPainter draws the queued triangles from back to front
+func (e *Engine) drawPainter(userData UserData) {
+	start := time.Now()
+	slices.SortStableFunc(e.painterQueue, func(a, b paintedTriangle) int {
+		return cmp.Compare(b.distance, a.distance)
+	})
+
+	var mesh *Mesh
+	var uniforms *Uniforms
+	for i := range e.painterQueue {
+		painted := &e.painterQueue[i]
+		if painted.mesh != mesh {
+			mesh = painted.mesh
+			uniforms = e.meshUniforms(mesh)
+		}
+		e.drawTriangle(&painted.triangle, mesh.material, uniforms, userData)
+	}
+	e.painterQueue = e.painterQueue[:0]
+	e.Metrics.RasterizationTime += time.Since(start)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestRetro_Dither(t *testing.T) {
+	retro := &Retro{Dither: true}
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+
+	// 100 lies between two 15-bit levels, the pattern mixes both and keeps the
+	// average
+	levels := map[uint8]int{}
+	sum := 0
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := retro.dither(x, y, gray)
+			if c.R != c.G || c.R != c.B || c.A != 255 {
+				t.Fatalf("expected a gray, got %v", c)
+			}
+			if level := math.Round(float64(c.R) * 31 / 255); uint8(level*255/31) != c.R {
+				t.Fatalf("expected a 15-bit color, got %v", c)
+			}
+			levels[c.R]++
+			sum += int(c.R)
+		}
+	}
+	if len(levels) != 2 {
+		t.Fatalf("expected two levels, got %v", levels)
+	}
+	if average := float64(sum) / 16; math.Abs(average-100) > 2 {
+		t.Fatalf("expected an average of 100, got %v", average)
+	}
+
+	// A black and white palette dithers a mid gray into a checker of both
+	retro.Palette = color.Palette{color.Black, color.White}
+	white := 0
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := retro.dither(x, y, color.RGBA{R: 128, G: 128, B: 128, A: 255})
+			if c == (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+				white++
+			} else if c != (color.RGBA{A: 255}) {
+				t.Fatalf("expected a color of the palette, got %v", c)
+			}
+		}
+	}
+	if white < 6 || white > 10 {
+		t.Fatalf("expected about half of the pixels white, got %d", white)
+	}
+}
+
+func TestRetro_Projection(t *testing.T) {
+	engine := NewEngine(64, 64, 90, nil, nil)
+	engine.SetRetro(&Retro{AffineTextures: true, VertexSnap: 4})
+
+	triangle := Triangle{}
+	for i := range triangle.Vertices {
+		triangle.Vertices[i] = Vector3d{X: 0.13 * float64(i), Y: -0.21 * float64(i), Z: 0.5, W: 2 + float64(i)}
+		triangle.Attributes[i][AttributeW] = 1
+		triangle.Attributes[i][AttributeU] = 0.25 * float64(i)
+	}
+	engine.projectToScreen(&triangle)
+
+	for i, vertex := range triangle.Vertices {
+		if math.Mod(vertex.X, 4) != 0 || math.Mod(vertex.Y, 4) != 0 {
+			t.Fatalf("vertex %d: expected to be snapped to the grid, got %v/%v", i, vertex.X, vertex.Y)
+		}
+		if u := triangle.Attributes[i][AttributeU]; u != 0.25*float64(i) {
+			t.Fatalf("vertex %d: expected the attribute to stay undivided, got %v", i, u)
+		}
+		if w := triangle.Attributes[i][AttributeW]; w != 1/(2+float64(i)) {
+			t.Fatalf("vertex %d: expected the depth to be divided, got %v", i, w)
+		}
+	}
+}
+
+func TestRetro_PainterSort(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	sky := color.RGBA{B: 255, A: 255}
+	box := func(c color.RGBA, z float64) *Mesh {
+		mesh := Box(1, 1, 1, 1, 1, 1)
+		for i := range mesh.triangles {
+			mesh.triangles[i].Color = c
+		}
+		mesh.SetMeshPositionRelative(0, 0, z)
+		return mesh
+	}
+
+	// The near box is added first, so it is only visible if the triangles are
+	// sorted. The colors are exact 15-bit colors, dithering keeps them
+	drawn := map[[2]int]color.RGBA{}
+	engine := NewEngine(32, 32, 90, func(x, y int, c color.Color, userData UserData) {
+		drawn[[2]int{x, y}] = color.RGBAModel.Convert(c).(color.RGBA)
+	}, nil)
+	engine.AddMesh(box(red, 3))
+	engine.AddMesh(box(green, 6))
+	engine.SetBackground(&SolidBackground{Color: sky})
+	engine.SetRetro(&Retro{PainterSort: true, Dither: true})
+	engine.Render(nil)
+
+	if c := drawn[[2]int{16, 16}]; c != red {
+		t.Fatalf("expected the near box in the center, got %v", c)
+	}
+	if c := drawn[[2]int{0, 0}]; c != sky {
+		t.Fatalf("expected the background in the corner, got %v", c)
+	}
+	for _, depth := range engine.depthBuffer.Entries {
+		if depth != 0 {
+			t.Fatalf("expected no depth writes, got %v", depth)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"image/color"
+	"math"
+	"time"
+)
+
+// LightType selects how a light projects shadows
+type LightType int
+
+const (
+	// LightDirectional is a light far away like the sun. All rays are parallel,
+	// the shadow map covers a square area around the position of the light
+	LightDirectional LightType = iota
+
+	// LightSpot shines from its position into a cone around its direction
+	LightSpot
+)
+
+// Defaults of new lights
+const (
+	DefaultShadowMapSize  = 512
+	DefaultShadowBias     = 0.05
+	DefaultShadowStrength = 0.6
+	DefaultLightRange     = 100
+)
+
+// Near plane of spot lights
+const lightNear = 0.1
+
+// Light casts shadows of meshes onto other meshes. The engine has no lighting
+// model, lights only darken the pixels in their shadow
+type Light struct {
+	Type LightType
+
+	// Position of a spot light. Directional lights cover the area around it
+	Position Vector3d
+
+	// Direction the light shines in
+	Direction Vector3d
+
+	// Opening angle of the cone of a spot light in degrees
+	FovDegrees float64
+
+	// Half the width of the square area covered by a directional light
+	Size float64
+
+	// Range is the distance covered by the shadow map. Directional lights cover
+	// half of it in front of and behind their position
+	Range float64
+
+	// Width and height of the shadow map in texels
+	ShadowMapSize int
+
+	// ShadowBias is the distance in world units a surface may lie behind the
+	// closest surface seen by the light and still be lit. If it is too small,
+	// surfaces shadow themselves, if it is too large, shadows detach from their
+	// casters
+	ShadowBias float64
+
+	// ShadowFilter is the radius in texels of the percentage closer filter that
+	// softens the edges of shadows, 0 for hard shadows
+	ShadowFilter int
+
+	// ShadowStrength is how much a pixel in full shadow is darkened, from 0 (not
+	// at all) to 1 (black)
+	ShadowStrength float64
+
+	// Transformation from world space into the clip space of the light and the
+	// view rendering the shadow map, whose depth buffer it is. Larger values are
+	// closer like in the depth buffer of the camera
+	viewProjection Matrix4x4
+	view           viewState
+	shadowMap      *DepthBuffer
+}
+
+// NewDirectionalLight creates a light shining in the given direction that casts
+// shadows within `size` units around the position
+func NewDirectionalLight(position, direction Vector3d, size float64) *Light {
+	return &Light{
+		Type:           LightDirectional,
+		Position:       position,
+		Direction:      direction,
+		Size:           size,
+		Range:          DefaultLightRange,
+		ShadowMapSize:  DefaultShadowMapSize,
+		ShadowBias:     DefaultShadowBias,
+		ShadowStrength: DefaultShadowStrength,
+	}
+}
+
+// NewSpotLight creates a light at the given position shining into a cone with the
+// given opening angle
+func NewSpotLight(position, direction Vector3d, fovDegrees float64) *Light {
+	return &Light{
+		Type:           LightSpot,
+		Position:       position,
+		Direction:      direction,
+		FovDegrees:     fovDegrees,
+		Range:          DefaultLightRange,
+		ShadowMapSize:  DefaultShadowMapSize,
+		ShadowBias:     DefaultShadowBias,
+		ShadowStrength: DefaultShadowStrength,
+	}
+}
+
+// ShadowMap returns the depth buffer rendered from the view of the light in the
+// last frame
+func (l *Light) ShadowMap() *DepthBuffer {
+	return l.shadowMap
+}
+
+// update recalculates the transformation of the light and clears its shadow map
+func (l *Light) update() {
+	size := max(l.ShadowMapSize, 1)
+	if l.shadowMap == nil || len(l.shadowMap.Entries) != size*size {
+		l.shadowMap = NewDepthBuffer(size, size)
+	} else {
+		l.shadowMap.Clear()
+	}
+
+	direction := l.Direction
+	direction.Normalize()
+	eye := l.Position
+	eye.W = 1
+
+	var projection Matrix4x4
+	near := 0.0
+	if l.Type == LightDirectional {
+		// Start behind the covered area, casters between the sun and the
+		// position need to be in the shadow map too
+		offset := direction.Mul(l.Range / 2)
+		eye = eye.Sub(&offset)
+		projection = Orthographic4x4(l.Size, 0, l.Range)
+	} else {
+		fov := 1.0 / math.Tan(ToRadians(l.FovDegrees/2))
+		near = lightNear
+		projection = Projection4x4(fov, 1, near, l.Range)
+	}
+
+	// The up vector must not be parallel to the direction
+	up := Vector3d{X: 0, Y: 1, Z: 0}
+	if math.Abs(direction.Y) > 0.99 {
+		up = Vector3d{X: 0, Y: 0, Z: 1}
+	}
+	target := eye.Add(&direction)
+
+	pointAt := Identity4x4()
+	pointAt.PointAt(&eye, &target, &up)
+	view := pointAt.Inverse()
+	l.viewProjection = view.MulM(&projection)
+
+	l.view = viewState{
+		w:            size,
+		h:            size,
+		W:            float64(size),
+		H:            float64(size),
+		camera:       eye,
+		view:         view,
+		direction:    direction,
+		projection:   projection,
+		near:         near,
+		orthographic: l.Type == LightDirectional,
+		depthBuffer:  l.shadowMap,
+	}
+}
+
+// texel maps a point in the clip space of the light to shadow map coordinates
+// like `projectToScreen` does
+func (l *Light) texel(clip *Vector3d) (float64, float64) {
+	size := float64(l.shadowMap.w)
+	return (1 - clip.X/clip.W) * 0.5 * size, (1 - clip.Y/clip.W) * 0.5 * size
+}
+
+// depth returns the shadow map value of a point in the clip space of the light,
+// the value the rasterizer interpolates for it
+func (l *Light) depth(clip *Vector3d) float64 {
+	if l.Type == LightDirectional {
+		return 1 - clip.Z
+	}
+	return 1 / clip.W
+}
+
+// distance converts a shadow map value back into the distance from the light
+func (l *Light) distance(depth float64) float64 {
+	if l.Type == LightDirectional {
+		return (1 - depth) * l.Range
+	}
+	return 1 / depth
+}
+
+// shadowed returns the share of the filter samples around a point in the clip
+// space of the light that lie in shadow. Samples outside of the shadow map are lit
+func (l *Light) shadowed(clip *Vector3d) float64 {
+	fx, fy := l.texel(clip)
+	x, y := int(math.Floor(fx)), int(math.Floor(fy))
+	distance := l.distance(l.depth(clip)) - l.ShadowBias
+
+	radius := max(l.ShadowFilter, 0)
+	size := l.shadowMap.w
+	shadowed, samples := 0, 0
+	for sy := y - radius; sy <= y+radius; sy++ {
+		for sx := x - radius; sx <= x+radius; sx++ {
+			samples++
+			if sx < 0 || sy < 0 || sx >= size || sy >= size {
+				continue
+			}
+			if depth := l.shadowMap.At(sx, sy); depth > 0 && l.distance(depth) < distance {
+				shadowed++
+			}
+		}
+	}
+	return float64(shadowed) / float64(samples)
+}
+
+// renderShadowMaps renders the depth of all shadow casting meshes from the view of
+// every light. Vertex shaders are not applied, meshes cast the shadow of their
+// triangles
+func (e *Engine) renderShadowMaps() {
+	start := time.Now()
+
+	// Every light takes the place of the camera like a viewport. Features of the
+	// final image are turned off and the metrics only cover the views
+	view, metrics := e.viewState, e.Metrics
+	retro, samples, debugMode := e.retro, e.samples, e.debugMode
+	e.retro, e.samples, e.debugMode = nil, nil, DebugNone
+	e.depthOnly = true
+
+	for _, light := range e.lights {
+		light.update()
+		e.viewState = light.view
+		for _, mesh := range e.meshes {
+			if mesh.castShadows {
+				e.renderMesh(mesh, nil)
+			}
+		}
+
+		// The level of detail is picked for the light, not for the camera
+		for _, terrain := range e.terrains {
+			for _, mesh := range terrain.Select(&light.Position) {
+				if mesh.castShadows {
+					mesh.updateWorld()
+					e.renderMesh(mesh, nil)
+				}
+			}
+		}
+	}
+
+	e.depthOnly = false
+	e.retro, e.samples, e.debugMode = retro, samples, debugMode
+	e.viewState, e.Metrics = view, metrics
+	e.Metrics.ShadowTime += time.Since(start)
+}
+
+// shadowLight returns the share of light reaching a pixel of a shadow receiving
+// triangle. Points outside of the shadow map of a light are lit by it
+func (e *Engine) shadowLight(current *Attributes, invW float64) float64 {
+	world := Vector3d{
+		X: current[AttributeWorldX] * invW,
+		Y: current[AttributeWorldY] * invW,
+		Z: current[AttributeWorldZ] * invW,
+		W: 1,
+	}
+
+	light := 1.0
+	for _, l := range e.lights {
+		clip := l.viewProjection.MulV(&world)
+		if clip.W <= 0 {
+			continue
+		}
+		light *= 1 - l.ShadowStrength*l.shadowed(&clip)
+	}
+	return light
+}
+
+// shadowColor darkens a color by the share of light reaching it
+func shadowColor(c color.RGBA64, light float64) color.RGBA64 {
+	return color.RGBA64{
+		R: uint16(float64(c.R) * light),
+		G: uint16(float64(c.G) * light),
+		B: uint16(float64(c.B) * light),
+		A: c.A,
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+)
+
+// shadowPixels counts the floor pixels of a shadow test scene
+type shadowPixels struct {
+	lit, partial, shadowed int
+}
+
+// renderShadowScene renders a cube floating above a gray floor, seen from above
+func renderShadowScene(light *Light, configure func(floor, cube *Mesh)) shadowPixels {
+	result := shadowPixels{}
+	engine := NewEngine(64, 32, 90, func(x, y int, c color.Color, userData UserData) {
+		r, g, b, _ := c.RGBA()
+		if r != g || g != b {
+			return
+		}
+		switch value := r >> 8; {
+		case value == 200:
+			result.lit++
+		case value == 80:
+			result.shadowed++
+		default:
+			result.partial++
+		}
+	}, nil)
+
+	floor := Plane(10, 10, 4, 4)
+	for i := range floor.triangles {
+		floor.triangles[i].Color = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+	}
+	engine.AddMesh(floor)
+
+	cube := ColoredCube()
+	cube.SetMeshPositionRelative(-0.5, 1, 2.5)
+	engine.AddMesh(cube)
+
+	if configure != nil {
+		configure(floor, cube)
+	}
+	if light != nil {
+		engine.AddLight(light)
+	}
+	engine.SetCameraPositionAbsolute(0, 4, 0, 0, 1.2)
+	engine.Render(nil)
+	return result
+}
+
+func TestShadow_Directional(t *testing.T) {
+	unlit := renderShadowScene(nil, nil)
+	if unlit.shadowed != 0 || unlit.partial != 0 {
+		t.Fatalf("expected no shadows without light, got %+v", unlit)
+	}
+
+	// The shadow falls towards the camera
+	light := NewDirectionalLight(Vector3d{X: 0, Y: 0, Z: 2}, Vector3d{X: 0, Y: -1, Z: -1}, 5)
+	lit := renderShadowScene(light, nil)
+	if lit.shadowed < 100 || lit.partial != 0 || lit.lit+lit.shadowed != unlit.lit {
+		t.Fatalf("expected a hard shadow, got %+v", lit)
+	}
+}
+
+func TestShadow_Spot(t *testing.T) {
+	light := NewSpotLight(Vector3d{X: 0, Y: 3, Z: 4.5}, Vector3d{X: 0, Y: -1, Z: -1}, 90)
+	pixels := renderShadowScene(light, nil)
+	if pixels.shadowed < 100 {
+		t.Fatalf("expected a shadow, got %+v", pixels)
+	}
+}
+
+func TestShadow_Flags(t *testing.T) {
+	for _, configure := range []func(floor, cube *Mesh){
+		func(floor, cube *Mesh) { cube.SetCastShadows(false) },
+		func(floor, cube *Mesh) { floor.SetReceiveShadows(false) },
+	} {
+		light := NewDirectionalLight(Vector3d{X: 0, Y: 0, Z: 2}, Vector3d{X: 0, Y: -1, Z: -1}, 5)
+		if pixels := renderShadowScene(light, configure); pixels.shadowed != 0 || pixels.partial != 0 {
+			t.Fatalf("expected no shadow on the floor, got %+v", pixels)
+		}
+	}
+}
+
+func TestShadow_Filter(t *testing.T) {
+	light := NewDirectionalLight(Vector3d{X: 0, Y: 0, Z: 2}, Vector3d{X: 0, Y: -1, Z: -1}, 5)
+	light.ShadowMapSize = 64
+	light.ShadowFilter = 2
+	pixels := renderShadowScene(light, nil)
+	if pixels.shadowed == 0 || pixels.partial == 0 {
+		t.Fatalf("expected soft shadow edges, got %+v", pixels)
+	}
+}
+
+func TestShadow_Pass(t *testing.T) {
+	for _, rasterizer := range []Rasterizer{RasterizerScanline, RasterizerEdgeFunction} {
+		engine := NewEngine(64, 32, 90, nil, &EngineOptions{SpanHook: noopSpanHook, Rasterizer: rasterizer})
+		cube := ColoredCube()
+		cube.SetMeshPositionRelative(-0.5, -0.5, -0.5)
+		engine.AddMesh(cube)
+		light := NewSpotLight(Vector3d{X: 0, Y: 0, Z: -5}, Vector3d{X: 0, Y: 0, Z: 1}, 90)
+		light.ShadowMapSize = 32
+		engine.AddLight(light)
+		engine.SetCameraPositionAbsolute(0, 0, -3, 0, 0)
+		engine.Render(nil)
+
+		// The cube covers the center of the shadow map at the distance of its
+		// front face
+		depth := light.ShadowMap().At(16, 16)
+		if distance := light.distance(depth); math.Abs(distance-4.5) > 0.01 {
+			t.Fatalf("rasterizer %d: expected the front face in the shadow map, got a distance of %v", rasterizer, distance)
+		}
+		if corner := light.ShadowMap().At(0, 0); corner != 0 {
+			t.Fatalf("rasterizer %d: expected the corner to be empty, got %v", rasterizer, corner)
+		}
+
+		// The views of the light do not count as part of the frame
+		if engine.Metrics.InputTriangles != len(cube.triangles) || engine.Metrics.ShadowTime == 0 {
+			t.Fatalf("rasterizer %d: expected the metrics of the camera, got %+v", rasterizer, engine.Metrics)
+		}
+	}
+}
+
+func TestShadow_TerrainLevelOfDetail(t *testing.T) {
+	texture, err := NewImageTexture(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A bumpy terrain far from the camera, the light is close to it
+	shadowMap := func(camera float64) []float64 {
+		terrain := NewTerrain(func(x, z float64) float64 {
+			return math.Sin(x*2) + math.Cos(z*2)
+		}, &TerrainOptions{Width: 8, Depth: 8, ChunksX: 1, ChunksZ: 1, ChunkResolution: 16, LODDistance: 10, Texture: texture})
+		light := NewDirectionalLight(Vector3d{X: 0, Y: 5, Z: 0}, Vector3d{X: 0, Y: -1, Z: 0.1}, 5)
+		light.ShadowMapSize = 32
+
+		engine := NewEngine(8, 8, 90, nil, &EngineOptions{SpanHook: noopSpanHook})
+		engine.AddTerrain(terrain)
+		engine.AddLight(light)
+		engine.SetCameraPositionAbsolute(0, 0, camera, 0, 0)
+		engine.Render(nil)
+		return slices.Clone(light.ShadowMap().Entries)
+	}
+
+	if !slices.Equal(shadowMap(-1), shadowMap(-1000)) {
+		t.Fatalf("expected the same shadow map regardless of the camera")
+	}
+}
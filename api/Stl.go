@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	stlHeaderSize = 80
+	stlFacetSize  = 50
+)
+
+// stlDefaultColor is used for facets without color information
+var stlDefaultColor = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+
+// LoadSTL implements support for ASCII and binary STL files. The format is detected
+// from the content. Facet normals are used as flat vertex normals, facets are
+// colored if the binary file carries VisCAM / SolidView colors
+func LoadSTL(r io.Reader) (*Mesh, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Binary files may also start with "solid", so the size is the more
+	// reliable indicator
+	if len(data) >= stlHeaderSize+4 {
+		count := int(binary.LittleEndian.Uint32(data[stlHeaderSize:]))
+		if len(data) == stlHeaderSize+4+count*stlFacetSize {
+			return loadBinarySTL(data, count)
+		}
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return loadAsciiSTL(data)
+	}
+
+	return nil, fmt.Errorf("invalid stl file: neither ASCII nor binary")
+}
+
+// stlTriangle creates a triangle from a facet. If the facet normal is missing
+// it is calculated from the vertices
+func stlTriangle(normal Vector3d, vertices [3]Vector3d, c color.Color) Triangle {
+	triangle := Triangle{
+		Vertices: vertices,
+		Color:    c,
+	}
+	if normal.Len() == 0 {
+		normal = triangle.Normal()
+	}
+	normal.W = 0
+	triangle.Normals = [3]Vector3d{normal, normal, normal}
+	return triangle
+}
+
+func loadBinarySTL(data []byte, count int) (*Mesh, error) {
+	result := NewMesh()
+
+	readVector := func(offset int, w float64) Vector3d {
+		return Vector3d{
+			X: float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset:]))),
+			Y: float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset+4:]))),
+			Z: float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset+8:]))),
+			W: w,
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		offset := stlHeaderSize + 4 + i*stlFacetSize
+		normal := readVector(offset, 0)
+		vertices := [3]Vector3d{
+			readVector(offset+12, 1),
+			readVector(offset+24, 1),
+			readVector(offset+36, 1),
+		}
+
+		// VisCAM / SolidView store a 15 bit color in the attribute bytes, the
+		// highest bit marks the color as valid
+		var c color.Color = stlDefaultColor
+		attribute := binary.LittleEndian.Uint16(data[offset+48:])
+		if attribute&0x8000 != 0 {
+			c = color.RGBA{
+				R: uint8((attribute >> 10 & 0x1F) * 255 / 31),
+				G: uint8((attribute >> 5 & 0x1F) * 255 / 31),
+				B: uint8((attribute & 0x1F) * 255 / 31),
+				A: 255,
+			}
+		}
+
+		result.AddTriangle(stlTriangle(normal, vertices, c))
+	}
+
+	return result, nil
+}
+
+func loadAsciiSTL(data []byte) (*Mesh, error) {
+	result := NewMesh()
+
+	parseVector := func(parts []string, w float64, line string, lineNumber int) (Vector3d, error) {
+		if len(parts) != 3 {
+			return Vector3d{}, fmt.Errorf("invalid line: '%s' in line %d", line, lineNumber)
+		}
+		var values [3]float64
+		for i, part := range parts {
+			value, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return Vector3d{}, fmt.Errorf("invalid float in line: '%s' in line %d", line, lineNumber)
+			}
+			values[i] = value
+		}
+		return Vector3d{X: values[0], Y: values[1], Z: values[2], W: w}, nil
+	}
+
+	var normal Vector3d
+	var vertices []Vector3d
+	inFacet := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+
+		switch parts[0] {
+		case "facet":
+			if len(parts) < 2 || parts[1] != "normal" {
+				return nil, fmt.Errorf("invalid facet line: '%s' in line %d", line, lineNumber)
+			}
+			n, err := parseVector(parts[2:], 0, line, lineNumber)
+			if err != nil {
+				return nil, err
+			}
+			normal = n
+			vertices = vertices[:0]
+			inFacet = true
+		case "vertex":
+			if !inFacet {
+				return nil, fmt.Errorf("vertex outside of facet: '%s' in line %d", line, lineNumber)
+			}
+			v, err := parseVector(parts[1:], 1, line, lineNumber)
+			if err != nil {
+				return nil, err
+			}
+			vertices = append(vertices, v)
+		case "endfacet":
+			if !inFacet || len(vertices) < 3 {
+				return nil, fmt.Errorf("incomplete facet in line %d", lineNumber)
+			}
+			// Facets are supposed to be triangles, polygons are split into a fan
+			for i := 1; i+1 < len(vertices); i++ {
+				result.AddTriangle(stlTriangle(normal, [3]Vector3d{vertices[0], vertices[i], vertices[i+1]}, stlDefaultColor))
+			}
+			inFacet = false
+		case "solid", "outer", "endloop", "endsolid":
+			continue
+		default:
+			return nil, fmt.Errorf("unexpected line: '%s' in line %d", line, lineNumber)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// WriteSTL writes the mesh in STL format, either binary or ASCII. The current world
+// transformation of the mesh is applied to the exported vertices
+func (m *Mesh) WriteSTL(w io.Writer, binaryFormat bool) error {
+	m.updateWorld()
+
+	writer := bufio.NewWriter(w)
+
+	if binaryFormat {
+		header := make([]byte, stlHeaderSize)
+		copy(header, "mini3d")
+		if _, err := writer.Write(header); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, binary.LittleEndian, uint32(len(m.triangles))); err != nil {
+			return err
+		}
+	} else {
+		if _, err := fmt.Fprintln(writer, "solid mini3d"); err != nil {
+			return err
+		}
+	}
+
+	facet := make([]float32, 12)
+	for _, triangle := range m.triangles {
+		transformed := Triangle{}
+		for i := range triangle.Vertices {
+			transformed.Vertices[i] = m.world.MulV(&triangle.Vertices[i])
+		}
+		normal := transformed.Normal()
+		if math.IsNaN(normal.X) {
+			// Degenerate triangle
+			normal = Vector3d{}
+		}
+
+		if binaryFormat {
+			facet[0], facet[1], facet[2] = float32(normal.X), float32(normal.Y), float32(normal.Z)
+			for i, v := range transformed.Vertices {
+				facet[3+i*3] = float32(v.X)
+				facet[4+i*3] = float32(v.Y)
+				facet[5+i*3] = float32(v.Z)
+			}
+			if err := binary.Write(writer, binary.LittleEndian, facet); err != nil {
+				return err
+			}
+			if err := binary.Write(writer, binary.LittleEndian, uint16(0)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := fmt.Fprintf(writer, "  facet normal %e %e %e\n    outer loop\n", normal.X, normal.Y, normal.Z); err != nil {
+			return err
+		}
+		for _, v := range transformed.Vertices {
+			if _, err := fmt.Fprintf(writer, "      vertex %e %e %e\n", v.X, v.Y, v.Z); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(writer, "    endloop\n  endfacet\n"); err != nil {
+			return err
+		}
+	}
+
+	if !binaryFormat {
+		if _, err := fmt.Fprintln(writer, "endsolid mini3d"); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
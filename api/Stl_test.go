@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLoadSTL_Ascii(t *testing.T) {
+	data := `solid test
+  facet normal 0 0 -1
+    outer loop
+      vertex 0 0 0
+      vertex 0 1 0
+      vertex 1 1 0
+    endloop
+  endfacet
+endsolid test
+`
+	mesh, err := LoadSTL(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("LoadSTL: %v", err)
+	}
+	if len(mesh.triangles) != 1 {
+		t.Fatalf("expected 1 triangle, got %d", len(mesh.triangles))
+	}
+	if mesh.triangles[0].Normals[2] != (Vector3d{X: 0, Y: 0, Z: -1}) {
+		t.Fatalf("expected facet normal, got %v", mesh.triangles[0].Normals[2])
+	}
+}
+
+func TestMesh_WriteSTL(t *testing.T) {
+	for _, binaryFormat := range []bool{true, false} {
+		mesh := ColoredCube()
+		mesh.Translate(1, 2, 3)
+
+		buffer := &bytes.Buffer{}
+		if err := mesh.WriteSTL(buffer, binaryFormat); err != nil {
+			t.Fatalf("WriteSTL(binary=%v): %v", binaryFormat, err)
+		}
+
+		loaded, err := LoadSTL(buffer)
+		if err != nil {
+			t.Fatalf("LoadSTL(binary=%v): %v", binaryFormat, err)
+		}
+		if len(loaded.triangles) != len(mesh.triangles) {
+			t.Fatalf("binary=%v: expected %d triangles, got %d", binaryFormat, len(mesh.triangles), len(loaded.triangles))
+		}
+
+		// The translation is baked into the exported vertices
+		for i, triangle := range loaded.triangles {
+			for v := range triangle.Vertices {
+				expected := mesh.triangles[i].Vertices[v]
+				actual := triangle.Vertices[v]
+				if math.Abs(actual.X-expected.X-1) > 1e-6 || math.Abs(actual.Y-expected.Y-2) > 1e-6 || math.Abs(actual.Z-expected.Z-3) > 1e-6 {
+					t.Fatalf("binary=%v: triangle %d vertex %d: expected %v translated, got %v", binaryFormat, i, v, expected, actual)
+				}
+			}
+		}
+	}
+}
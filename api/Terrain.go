@@ -0,0 +1,271 @@
+package api
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// HeightFunc returns the height of the terrain at the given position
+type HeightFunc func(x, z float64) float64
+
+// TerrainOptions configures the generation of a terrain. Zero values are replaced
+// by defaults
+type TerrainOptions struct {
+	// Dimensions of the terrain along the X and Z axes. The terrain is centered
+	// at origin. Defaults to 100x100
+	Width, Depth float64
+
+	// Number of chunks along the X and Z axes. Defaults to 4x4
+	ChunksX, ChunksZ int
+
+	// Number of grid cells along each side of a chunk at the highest level of
+	// detail. Every further level halves it. Defaults to 32
+	ChunkResolution int
+
+	// Number of levels of detail. Defaults to 3
+	LODLevels int
+
+	// Distance from the camera to the center of a chunk at which the first
+	// coarser level is used. Every further level starts at twice the distance
+	// of the previous one. Defaults to the size of a chunk
+	LODDistance float64
+
+	// Skirts hang down from the borders of every chunk to hide the cracks between
+	// chunks of different levels of detail. Defaults to 5% of the chunk size
+	SkirtDepth float64
+
+	// Maximum height of terrains generated from an image. Defaults to 10
+	Height float64
+
+	// Optional texture, stretched over the whole terrain. If not set, the texture
+	// atlas of the engine is used
+	Texture TextureAtlas
+}
+
+// withDefaults returns a copy of the options with all unset values replaced
+func (o *TerrainOptions) withDefaults() TerrainOptions {
+	result := TerrainOptions{}
+	if o != nil {
+		result = *o
+	}
+	if result.Width <= 0 {
+		result.Width = 100
+	}
+	if result.Depth <= 0 {
+		result.Depth = 100
+	}
+	if result.ChunksX <= 0 {
+		result.ChunksX = 4
+	}
+	if result.ChunksZ <= 0 {
+		result.ChunksZ = 4
+	}
+	if result.ChunkResolution <= 0 {
+		result.ChunkResolution = 32
+	}
+	if result.LODLevels <= 0 {
+		result.LODLevels = 3
+	}
+	chunkSize := math.Max(result.Width/float64(result.ChunksX), result.Depth/float64(result.ChunksZ))
+	if result.LODDistance <= 0 {
+		result.LODDistance = chunkSize
+	}
+	if result.SkirtDepth <= 0 {
+		result.SkirtDepth = chunkSize * 0.05
+	}
+	if result.Height <= 0 {
+		result.Height = 10
+	}
+	return result
+}
+
+// terrainChunk is a rectangular part of the terrain with one mesh per level of detail
+type terrainChunk struct {
+	center Vector3d
+	levels []*Mesh
+}
+
+// Terrain is a height field split into chunks. Every chunk is rendered at a level
+// of detail depending on its distance to the camera
+type Terrain struct {
+	opts   TerrainOptions
+	height HeightFunc
+	chunks []terrainChunk
+
+	// Meshes selected for the current camera position
+	selected []*Mesh
+}
+
+// NewTerrain generates a terrain from a height function
+func NewTerrain(height HeightFunc, opts *TerrainOptions) *Terrain {
+	terrain := &Terrain{
+		opts:   opts.withDefaults(),
+		height: height,
+	}
+
+	chunkWidth := terrain.opts.Width / float64(terrain.opts.ChunksX)
+	chunkDepth := terrain.opts.Depth / float64(terrain.opts.ChunksZ)
+
+	for cz := 0; cz < terrain.opts.ChunksZ; cz++ {
+		for cx := 0; cx < terrain.opts.ChunksX; cx++ {
+			x0 := -terrain.opts.Width/2 + float64(cx)*chunkWidth
+			z0 := -terrain.opts.Depth/2 + float64(cz)*chunkDepth
+
+			chunk := terrainChunk{}
+			chunk.center = Vector3d{
+				X: x0 + chunkWidth/2,
+				Y: height(x0+chunkWidth/2, z0+chunkDepth/2),
+				Z: z0 + chunkDepth/2,
+				W: 1,
+			}
+
+			for level := 0; level < terrain.opts.LODLevels; level++ {
+				resolution := max(terrain.opts.ChunkResolution>>level, 1)
+				chunk.levels = append(chunk.levels, terrain.chunkMesh(x0, z0, chunkWidth, chunkDepth, resolution))
+			}
+
+			terrain.chunks = append(terrain.chunks, chunk)
+		}
+	}
+
+	return terrain
+}
+
+// NewTerrainFromImage generates a terrain from a grayscale height map. Black is
+// mapped to a height of 0, white to `Height`. The image is stretched over the
+// whole terrain. Empty images are rejected
+func NewTerrainFromImage(img image.Image, opts *TerrainOptions) (*Terrain, error) {
+	o := opts.withDefaults()
+	height, err := ImageHeightFunc(img, o.Width, o.Depth, o.Height)
+	if err != nil {
+		return nil, err
+	}
+	return NewTerrain(height, &o), nil
+}
+
+// ImageHeightFunc returns a height function that samples a grayscale image stretched
+// over an area of the given dimensions centered at origin. Values between pixels
+// are interpolated. Empty images are rejected
+func ImageHeightFunc(img image.Image, width, depth, height float64) (HeightFunc, error) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("empty height map of size %dx%d", bounds.Dx(), bounds.Dy())
+	}
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// Convert the image once, sampling it through the interface is slow
+	values := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray := color.Gray16Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray16)
+			values[y*w+x] = float64(gray.Y) / 0xFFFF * height
+		}
+	}
+
+	at := func(x, y int) float64 {
+		x = max(0, min(w-1, x))
+		y = max(0, min(h-1, y))
+		return values[y*w+x]
+	}
+
+	return func(x, z float64) float64 {
+		px := (x/width + 0.5) * float64(w-1)
+		py := (z/depth + 0.5) * float64(h-1)
+		x0, y0 := int(math.Floor(px)), int(math.Floor(py))
+		fx, fy := px-float64(x0), py-float64(y0)
+		top := at(x0, y0)*(1-fx) + at(x0+1, y0)*fx
+		bottom := at(x0, y0+1)*(1-fx) + at(x0+1, y0+1)*fx
+		return top*(1-fy) + bottom*fy
+	}, nil
+}
+
+// HeightAt returns the height of the terrain at the given position
+func (t *Terrain) HeightAt(x, z float64) float64 {
+	return t.height(x, z)
+}
+
+// normalAt estimates the surface normal from the height function. The sample
+// distance is the cell size of the highest level of detail, so all levels share
+// the same normals
+func (t *Terrain) normalAt(x, z float64) Vector3d {
+	dx := t.opts.Width / float64(t.opts.ChunksX*t.opts.ChunkResolution)
+	dz := t.opts.Depth / float64(t.opts.ChunksZ*t.opts.ChunkResolution)
+	slopeX := (t.height(x+dx, z) - t.height(x-dx, z)) / (2 * dx)
+	slopeZ := (t.height(x, z+dz) - t.height(x, z-dz)) / (2 * dz)
+	normal := Vector3d{X: -slopeX, Y: 1, Z: -slopeZ}
+	normal.Normalize()
+	return normal
+}
+
+// vertexAt returns position, texture coordinate and normal of the terrain at the
+// given position, optionally lowered for skirts
+func (t *Terrain) vertexAt(x, z, lower float64) (Vector3d, VectorUv, Vector3d) {
+	position := Vector3d{X: x, Y: t.height(x, z) - lower, Z: z, W: 1}
+	uv := VectorUv{U: x/t.opts.Width + 0.5, V: 0.5 - z/t.opts.Depth}
+	return position, uv, t.normalAt(x, z)
+}
+
+// chunkMesh generates the mesh of a single chunk at the given resolution, including
+// its skirts
+func (t *Terrain) chunkMesh(x0, z0, width, depth float64, resolution int) *Mesh {
+	mesh := NewMesh()
+	cellWidth := width / float64(resolution)
+	cellDepth := depth / float64(resolution)
+	x1, z1 := x0+width, z0+depth
+	skirt := t.opts.SkirtDepth
+
+	// Surface, facing up
+	addSurface(mesh, resolution, resolution, func(i, j int) (Vector3d, VectorUv, Vector3d) {
+		return t.vertexAt(x0+float64(i)*cellWidth, z0+float64(j)*cellDepth, 0)
+	})
+
+	// Skirts, facing away from the chunk. They go from the lowered (j = 0) to the
+	// surface vertex (j = 1) along each border
+	edge := func(i, j int, x, z float64) (Vector3d, VectorUv, Vector3d) {
+		return t.vertexAt(x, z, float64(1-j)*skirt)
+	}
+	addSurface(mesh, resolution, 1, func(i, j int) (Vector3d, VectorUv, Vector3d) {
+		return edge(i, j, x0+float64(i)*cellWidth, z0)
+	})
+	addSurface(mesh, resolution, 1, func(i, j int) (Vector3d, VectorUv, Vector3d) {
+		return edge(i, j, x1-float64(i)*cellWidth, z1)
+	})
+	addSurface(mesh, resolution, 1, func(i, j int) (Vector3d, VectorUv, Vector3d) {
+		return edge(i, j, x0, z1-float64(i)*cellDepth)
+	})
+	addSurface(mesh, resolution, 1, func(i, j int) (Vector3d, VectorUv, Vector3d) {
+		return edge(i, j, x1, z0+float64(i)*cellDepth)
+	})
+
+	if t.opts.Texture != nil {
+		for i := range mesh.triangles {
+			mesh.triangles[i].Texture = t.opts.Texture
+		}
+		mesh.updateTextures()
+	}
+
+	return mesh
+}
+
+// levelAt returns the level of detail for a chunk at the given distance
+func (t *Terrain) levelAt(distance float64) int {
+	if distance < t.opts.LODDistance {
+		return 0
+	}
+	level := int(math.Log2(distance/t.opts.LODDistance)) + 1
+	return min(level, t.opts.LODLevels-1)
+}
+
+// Select picks the level of detail of every chunk for the given camera position
+// and returns the meshes to render
+func (t *Terrain) Select(camera *Vector3d) []*Mesh {
+	t.selected = t.selected[:0]
+	for i := range t.chunks {
+		chunk := &t.chunks[i]
+		offset := chunk.center.Sub(camera)
+		t.selected = append(t.selected, chunk.levels[t.levelAt(offset.Len())])
+	}
+	return t.selected
+}
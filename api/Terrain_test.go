@@ -0,0 +1,72 @@
+package api
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestTerrain_Select(t *testing.T) {
+	terrain := NewTerrain(func(x, z float64) float64 {
+		return math.Sin(x) + math.Cos(z)
+	}, &TerrainOptions{
+		Width:           40,
+		Depth:           40,
+		ChunksX:         4,
+		ChunksZ:         4,
+		ChunkResolution: 8,
+		LODLevels:       3,
+		LODDistance:     10,
+	})
+
+	// Close to the first chunk, far from the last one
+	camera := Vector3d{X: -15, Y: 0, Z: -15, W: 1}
+	meshes := terrain.Select(&camera)
+	if len(meshes) != 16 {
+		t.Fatalf("expected 16 chunks, got %d", len(meshes))
+	}
+
+	// 8x8 cells and four skirts at full detail, 2x2 cells at the coarsest level
+	if count := len(meshes[0].triangles); count != 8*8*2+4*8*2 {
+		t.Fatalf("expected the nearest chunk at full detail, got %d triangles", count)
+	}
+	if count := len(meshes[15].triangles); count != 2*2*2+4*2*2 {
+		t.Fatalf("expected the farthest chunk at the coarsest level, got %d triangles", count)
+	}
+}
+
+func TestImageHeightFunc(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 1))
+	img.SetGray(1, 0, color.Gray{Y: 255})
+
+	height, err := ImageHeightFunc(img, 10, 10, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := height(-5, 0); h != 0 {
+		t.Fatalf("expected 0 at the black pixel, got %v", h)
+	}
+	if h := height(0, 0); math.Abs(h-2) > 1e-9 {
+		t.Fatalf("expected interpolated height 2, got %v", h)
+	}
+	if h := height(5, 0); math.Abs(h-4) > 1e-9 {
+		t.Fatalf("expected 4 at the white pixel, got %v", h)
+	}
+}
+
+func TestNewTerrainFromImage(t *testing.T) {
+	if _, err := NewTerrainFromImage(image.NewGray(image.Rect(0, 0, 0, 0)), nil); err == nil {
+		t.Fatal("expected an error for an empty image")
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	img.SetGray(2, 2, color.Gray{Y: 255})
+	terrain, err := NewTerrainFromImage(img, &TerrainOptions{Width: 4, Depth: 4, ChunksX: 1, ChunksZ: 1, ChunkResolution: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := terrain.HeightAt(-2, -2); h != 0 {
+		t.Fatalf("expected 0 at the black corner, got %v", h)
+	}
+}
@@ -14,6 +14,35 @@ type Triangle struct {
 
 	// Optional color
 	Color color.Color
+
+	// Optional vertex normals, zero if the source did not provide any
+	Normals [3]Vector3d
+
+	// Optional texture. If set it takes precedence over the texture atlas
+	// of the engine
+	Texture TextureAtlas
+
+	// Optional per vertex colors. If any is set, they are interpolated across the
+	// face and take precedence over `Color` and the texture. Vertices without a
+	// color use `Color`, or white if that is not set either
+	VertexColors [3]color.Color
+
+	// Values interpolated across the triangle by the rasterizer. They are filled
+	// in by the render pipeline, see `AttributeW` for the layout
+	Attributes [3]Attributes
+
+	// Set by the render pipeline if the color attributes hold vertex colors. The
+	// pipeline does not carry `VertexColors`, clipping them would allocate
+	colorAttributes bool
+
+	// Set by the render pipeline: index of the source triangle in the frame and
+	// whether it faces away from the camera, used by the debug modes
+	index    int
+	backface bool
+
+	// Set by the render pipeline if the attributes hold the world position for
+	// the shadow lookup
+	receiveShadows bool
 }
 
 // Copy returns a new triangle with exactly the same properties
@@ -24,6 +53,10 @@ func (t *Triangle) Copy() Triangle {
 	duplicate.Vertices[2] = t.Vertices[2].Copy()
 	duplicate.UVs = t.UVs.Copy()
 	duplicate.Color = t.Color
+	duplicate.Normals = t.Normals
+	duplicate.Texture = t.Texture
+	duplicate.VertexColors = t.VertexColors
+	duplicate.Attributes = t.Attributes
 	return duplicate
 }
 
@@ -36,6 +69,33 @@ func (t *Triangle) SetTrianglePositionRelative(dx, dy, dz float64) {
 	}
 }
 
+// hasNormals returns true if any vertex of the triangle has a normal set
+func (t *Triangle) hasNormals() bool {
+	for i := range t.Normals {
+		if t.Normals[i].X != 0 || t.Normals[i].Y != 0 || t.Normals[i].Z != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasVertexColors returns true if any vertex of the triangle has a color set
+func (t *Triangle) hasVertexColors() bool {
+	return t.VertexColors[0] != nil || t.VertexColors[1] != nil || t.VertexColors[2] != nil
+}
+
+// vertexColor returns the color of a vertex, falling back to the triangle color
+// and white
+func (t *Triangle) vertexColor(index int) color.Color {
+	if t.VertexColors[index] != nil {
+		return t.VertexColors[index]
+	}
+	if t.Color != nil {
+		return t.Color
+	}
+	return color.White
+}
+
 // RGBA returns the color components of the triangle, implementing the
 // `Color` interface
 func (t *Triangle) RGBA() (r, g, b, a uint32) {
@@ -52,6 +112,17 @@ func (t *Triangle) Normal() Vector3d {
 	return normal
 }
 
+// ScaleAttributes divides the attributes of every vertex by its `W` component, so
+// they can be interpolated linearly in screen space. Call it before `ScaleW`
+func (t *Triangle) ScaleAttributes() {
+	for n := range t.Attributes {
+		invW := 1 / t.Vertices[n].W
+		for i := range t.Attributes[n] {
+			t.Attributes[n][i] *= invW
+		}
+	}
+}
+
 func (t *Triangle) ScaleW() {
 	t.Vertices[0] = t.Vertices[0].Div(t.Vertices[0].W)
 	t.Vertices[1] = t.Vertices[1].Div(t.Vertices[1].W)
@@ -76,6 +147,52 @@ func vectorIntersectPlane(p, n, start, end *Vector3d, t *float64) Vector3d {
 	return start.Add(&lineToIntersect)
 }
 
+// copyFace copies the properties of the triangle that do not depend on the
+// vertices to `out`
+func (t *Triangle) copyFace(out *Triangle) {
+	out.Color = t.Color
+	out.Texture = t.Texture
+	out.colorAttributes = t.colorAttributes
+	out.index = t.index
+	out.backface = t.backface
+	out.receiveShadows = t.receiveShadows
+}
+
+// copyVertex copies vertex `from` of the triangle with all its values to vertex `to`
+// of `out`
+func (t *Triangle) copyVertex(from int, out *Triangle, to int) {
+	out.Vertices[to] = t.Vertices[from]
+	out.UVs[to] = t.UVs[from]
+	out.Normals[to] = t.Normals[from]
+	out.VertexColors[to] = t.VertexColors[from]
+	out.Attributes[to] = t.Attributes[from]
+}
+
+// intersectVertex sets vertex `to` of `out` to the point where the edge from vertex
+// `inside` to vertex `outside` intersects the plane. All values of the vertex are
+// interpolated
+func (t *Triangle) intersectVertex(p, n *Vector3d, inside, outside int, out *Triangle, to int) {
+	s := 0.0
+	out.Vertices[to] = vectorIntersectPlane(p, n, &t.Vertices[inside], &t.Vertices[outside], &s)
+	out.Vertices[to].W = s*(t.Vertices[outside].W-t.Vertices[inside].W) + t.Vertices[inside].W
+
+	a, b := &t.UVs[inside], &t.UVs[outside]
+	out.UVs[to].U = s*(b.U-a.U) + a.U
+	out.UVs[to].V = s*(b.V-a.V) + a.V
+	out.UVs[to].W = s*(b.W-a.W) + a.W
+
+	normalDelta := t.Normals[outside].Sub(&t.Normals[inside])
+	normalDelta = normalDelta.Mul(s)
+	out.Normals[to] = t.Normals[inside].Add(&normalDelta)
+
+	out.VertexColors[to] = nil
+	if t.hasVertexColors() {
+		out.VertexColors[to] = lerpColor(t.vertexColor(inside), t.vertexColor(outside), s)
+	}
+
+	out.Attributes[to] = t.Attributes[inside].Lerp(&t.Attributes[outside], s)
+}
+
 // ClipAgainstPlane splits into two if one or more vertices intersect with screen boundaries
 func (t *Triangle) ClipAgainstPlane(p, n *Vector3d, triangleOut1, triangleOut2 *Triangle) int {
 	n.Normalize()
@@ -84,55 +201,21 @@ func (t *Triangle) ClipAgainstPlane(p, n *Vector3d, triangleOut1, triangleOut2 *
 		return n.X*point.X + n.Y*point.Y + n.Z*point.Z - n.Dot(p)
 	}
 
-	d0 := dist(&t.Vertices[0])
-	d1 := dist(&t.Vertices[1])
-	d2 := dist(&t.Vertices[2])
-
 	insidePointCount := 0
-	insideUVsCount := 0
-	insidePoints := [3]*Vector3d{}
-	insideUVs := [3]*VectorUv{}
+	insidePoints := [3]int{}
 	outsidePointCount := 0
-	outsideUVsCount := 0
-	outsidePoints := [3]*Vector3d{}
-	outsideUVs := [3]*VectorUv{}
+	outsidePoints := [3]int{}
 
 	// Check how many points of the triangle lie inside the
 	// screen boundaries
-	if d0 >= 0 {
-		insidePoints[insidePointCount] = &t.Vertices[0]
-		insidePointCount += 1
-		insideUVs[insideUVsCount] = &t.UVs[0]
-		insideUVsCount += 1
-	} else {
-		outsidePoints[outsidePointCount] = &t.Vertices[0]
-		outsidePointCount += 1
-		outsideUVs[outsideUVsCount] = &t.UVs[0]
-		outsideUVsCount += 1
-	}
-
-	if d1 >= 0 {
-		insidePoints[insidePointCount] = &t.Vertices[1]
-		insidePointCount += 1
-		insideUVs[insideUVsCount] = &t.UVs[1]
-		insideUVsCount += 1
-	} else {
-		outsidePoints[outsidePointCount] = &t.Vertices[1]
-		outsidePointCount += 1
-		outsideUVs[outsideUVsCount] = &t.UVs[1]
-		outsideUVsCount += 1
-	}
-
-	if d2 >= 0 {
-		insidePoints[insidePointCount] = &t.Vertices[2]
-		insidePointCount += 1
-		insideUVs[insideUVsCount] = &t.UVs[2]
-		insideUVsCount += 1
-	} else {
-		outsidePoints[outsidePointCount] = &t.Vertices[2]
-		outsidePointCount += 1
-		outsideUVs[outsideUVsCount] = &t.UVs[2]
-		outsideUVsCount += 1
+	for i := range t.Vertices {
+		if dist(&t.Vertices[i]) >= 0 {
+			insidePoints[insidePointCount] = i
+			insidePointCount += 1
+		} else {
+			outsidePoints[outsidePointCount] = i
+			outsidePointCount += 1
+		}
 	}
 
 	// No points of the triangle are inside screen boundaries, the
@@ -151,22 +234,12 @@ func (t *Triangle) ClipAgainstPlane(p, n *Vector3d, triangleOut1, triangleOut2 *
 	// Two points lie outside of screen boundaries. We can clip the triangle into
 	// a new, smaller, triangle
 	if insidePointCount == 1 && outsidePointCount == 2 {
-		triangleOut1.Color = t.Color
+		t.copyFace(triangleOut1)
 
-		// Keep the inside vertex and UV
-		triangleOut1.Vertices[0] = *insidePoints[0]
-		triangleOut1.UVs[0] = *insideUVs[0]
-
-		t := 0.0
-		triangleOut1.Vertices[1] = vectorIntersectPlane(p, n, insidePoints[0], outsidePoints[0], &t)
-		triangleOut1.UVs[1].U = t*(outsideUVs[0].U-insideUVs[0].U) + insideUVs[0].U
-		triangleOut1.UVs[1].V = t*(outsideUVs[0].V-insideUVs[0].V) + insideUVs[0].V
-		triangleOut1.UVs[1].W = t*(outsideUVs[0].W-insideUVs[0].W) + insideUVs[0].W
-
-		triangleOut1.Vertices[2] = vectorIntersectPlane(p, n, insidePoints[0], outsidePoints[1], &t)
-		triangleOut1.UVs[2].U = t*(outsideUVs[1].U-insideUVs[0].U) + insideUVs[0].U
-		triangleOut1.UVs[2].V = t*(outsideUVs[1].V-insideUVs[0].V) + insideUVs[0].V
-		triangleOut1.UVs[2].W = t*(outsideUVs[1].W-insideUVs[0].W) + insideUVs[0].W
+		// Keep the inside vertex
+		t.copyVertex(insidePoints[0], triangleOut1, 0)
+		t.intersectVertex(p, n, insidePoints[0], outsidePoints[0], triangleOut1, 1)
+		t.intersectVertex(p, n, insidePoints[0], outsidePoints[1], triangleOut1, 2)
 
 		return 1
 	}
@@ -174,35 +247,22 @@ func (t *Triangle) ClipAgainstPlane(p, n *Vector3d, triangleOut1, triangleOut2 *
 	// Two points lie inside of screen boundaries, one outside. Triangle needs to be clipped
 	// into two smaller triangles
 	if insidePointCount == 2 && outsidePointCount == 1 {
-		triangleOut1.Color = t.Color
-		triangleOut2.Color = t.Color
+		t.copyFace(triangleOut1)
+		t.copyFace(triangleOut2)
 
 		// The first triangle consists of the two inside points and a new
 		// point determined by the location where one side of the triangle
 		// intersects with the plane
-		triangleOut1.Vertices[0] = *insidePoints[0]
-		triangleOut1.Vertices[1] = *insidePoints[1]
-		triangleOut1.UVs[0] = *insideUVs[0]
-		triangleOut1.UVs[1] = *insideUVs[1]
-
-		t := 0.0
-
-		triangleOut1.Vertices[2] = vectorIntersectPlane(p, n, insidePoints[0], outsidePoints[0], &t)
-		triangleOut1.UVs[2].U = t*(outsideUVs[0].U-insideUVs[0].U) + insideUVs[0].U
-		triangleOut1.UVs[2].V = t*(outsideUVs[0].V-insideUVs[0].V) + insideUVs[0].V
-		triangleOut1.UVs[2].W = t*(outsideUVs[0].W-insideUVs[0].W) + insideUVs[0].W
+		t.copyVertex(insidePoints[0], triangleOut1, 0)
+		t.copyVertex(insidePoints[1], triangleOut1, 1)
+		t.intersectVertex(p, n, insidePoints[0], outsidePoints[0], triangleOut1, 2)
 
 		// The second triangle is composed of one of he inside points, a
 		// new point determined by the intersection of the other side of the
 		// triangle and the plane, and the newly created point above
-		triangleOut2.Vertices[0] = *insidePoints[1]
-		triangleOut2.UVs[0] = *insideUVs[1]
-		triangleOut2.Vertices[1] = triangleOut1.Vertices[2]
-		triangleOut2.UVs[1] = triangleOut1.UVs[2]
-		triangleOut2.Vertices[2] = vectorIntersectPlane(p, n, insidePoints[1], outsidePoints[0], &t)
-		triangleOut2.UVs[2].U = t*(outsideUVs[0].U-insideUVs[1].U) + insideUVs[1].U
-		triangleOut2.UVs[2].V = t*(outsideUVs[0].V-insideUVs[1].V) + insideUVs[1].V
-		triangleOut2.UVs[2].W = t*(outsideUVs[0].W-insideUVs[1].W) + insideUVs[1].W
+		t.copyVertex(insidePoints[1], triangleOut2, 0)
+		triangleOut1.copyVertex(2, triangleOut2, 1)
+		t.intersectVertex(p, n, insidePoints[1], outsidePoints[0], triangleOut2, 2)
 
 		// Return two newly formed triangles which form a quad
 		return 2
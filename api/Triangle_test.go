@@ -0,0 +1,104 @@
+package api
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestTriangle_ClipAgainstPlaneAttributes(t *testing.T) {
+	triangle := Triangle{
+		Vertices: [3]Vector3d{
+			{X: 0, Y: 0, Z: -1, W: 1},
+			{X: 0, Y: 1, Z: 1, W: 1},
+			{X: 1, Y: 0, Z: 1, W: 1},
+		},
+	}
+	for i := range triangle.Attributes {
+		for k := range triangle.Attributes[i] {
+			// Every slot is a linear function of z
+			triangle.Attributes[i][k] = triangle.Vertices[i].Z*float64(k+1) + 10
+		}
+	}
+
+	p := Vector3d{X: 0, Y: 0, Z: 0}
+	n := Vector3d{X: 0, Y: 0, Z: 1}
+	clipped := [2]Triangle{}
+	count := triangle.ClipAgainstPlane(&p, &n, &clipped[0], &clipped[1])
+	if count != 2 {
+		t.Fatalf("expected 2 triangles, got %d", count)
+	}
+
+	for c := 0; c < count; c++ {
+		for i := range clipped[c].Vertices {
+			z := clipped[c].Vertices[i].Z
+			if z < -1e-9 {
+				t.Fatalf("vertex behind the plane: %v", clipped[c].Vertices[i])
+			}
+			for k, value := range clipped[c].Attributes[i] {
+				if expected := z*float64(k+1) + 10; math.Abs(value-expected) > 1e-9 {
+					t.Fatalf("attribute %d: expected %v, got %v", k, expected, value)
+				}
+			}
+		}
+	}
+}
+
+func TestTriangle_VertexColorsInterpolated(t *testing.T) {
+	mesh := NewMesh()
+	mesh.AddTriangle(Triangle{
+		Vertices: [3]Vector3d{
+			{X: -1, Y: -1, Z: 2, W: 1},
+			{X: 0, Y: 1, Z: 2, W: 1},
+			{X: 1, Y: -1, Z: 4, W: 1},
+		},
+		VertexColors: [3]color.Color{color.RGBA{R: 255, A: 255}, color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}},
+	})
+
+	mixed := 0
+	engine := NewEngine(64, 64, 90, func(x, y int, c color.Color, userData UserData) {
+		r, _, b, _ := c.RGBA()
+		if r > 0 && b > 0 {
+			mixed++
+		}
+		if r+b < 0xFFFF-0x100 || r+b > 0xFFFF+0x100 {
+			t.Fatalf("interpolated color does not add up: %v", c)
+		}
+	}, nil)
+	engine.AddMesh(mesh)
+	engine.Render(nil)
+
+	if engine.Metrics.Triangles != 1 || mixed == 0 {
+		t.Fatalf("expected interpolated colors, got %d mixed pixels", mixed)
+	}
+}
+
+func BenchmarkTriangle_ClipAgainstPlane(b *testing.B) {
+	cases := []struct {
+		name string
+		z    [3]float64
+	}{
+		{"inside", [3]float64{1, 1, 1}},
+		{"one-inside", [3]float64{1, -1, -1}},
+		{"two-inside", [3]float64{1, 1, -1}},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			triangle := Triangle{
+				Vertices: [3]Vector3d{
+					{X: 0, Y: 0, Z: c.z[0], W: 1},
+					{X: 0, Y: 1, Z: c.z[1], W: 1},
+					{X: 1, Y: 0, Z: c.z[2], W: 1},
+				},
+			}
+			p := Vector3d{X: 0, Y: 0, Z: 0}
+			n := Vector3d{X: 0, Y: 0, Z: 1}
+			clipped := [2]Triangle{}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				triangle.ClipAgainstPlane(&p, &n, &clipped[0], &clipped[1])
+			}
+		})
+	}
+}
@@ -35,7 +35,7 @@ func TestVector3d_Add(t *testing.T) {
 
 	for _, test := range testCases {
 		result := test.a.Add(test.b)
-		if !reflect.DeepEqual(result, test.expected) {
+		if !reflect.DeepEqual(result, *test.expected) {
 			t.Fatalf("Add: expected %v, got %v", test.expected, result)
 		}
 	}
@@ -67,7 +67,7 @@ func TestVector3d_Sub(t *testing.T) {
 
 	for _, test := range testCases {
 		result := test.a.Sub(test.b)
-		if !reflect.DeepEqual(result, test.expected) {
+		if !reflect.DeepEqual(result, *test.expected) {
 			t.Fatalf("Sub: expected %v, got %v", test.expected, result)
 		}
 	}
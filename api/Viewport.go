@@ -0,0 +1,154 @@
+package api
+
+import "math"
+
+// Default depth range of the projection
+const (
+	DefaultNear = 0.1
+	DefaultFar  = 1000
+)
+
+// viewState is what a camera needs to render into a rectangle of the target. The
+// engine has one for the whole target and every viewport has its own
+type viewState struct {
+	// Internal viewport dimensions
+	w, h int
+	W, H float64
+
+	// Position of the rectangle in the target
+	offsetX, offsetY int
+
+	// Camera yaw angle in radians (left/right)
+	yaw float64
+
+	// Camera pitch angle in radians (up/down)
+	pitch float64
+
+	// Camera position
+	camera Vector3d
+
+	// View matrix
+	view Matrix4x4
+
+	// Vector pointing to the current forward direction of the camera
+	direction Vector3d
+
+	// Projection matrix to project from 3D into 2D, geometry closer than near is
+	// clipped
+	projection Matrix4x4
+	near       float64
+
+	// Orthographic projections have no perspective, the depth buffer stores
+	// 1 - z instead of 1/w
+	orthographic bool
+
+	// depthBuffer helps to avoid drawing pixels that have already been filled
+	// this implementation does not allow for transparent materials
+	depthBuffer *DepthBuffer
+}
+
+// newViewState creates the state of a camera at the origin that renders into the
+// given rectangle
+func newViewState(x, y, w, h int, fovDegrees float64) viewState {
+	v := viewState{w: w, h: h, W: float64(w), H: float64(h), offsetX: x, offsetY: y}
+	v.camera = Vector3d{
+		X: 0,
+		Y: 0,
+		Z: 0,
+		W: 1,
+	}
+	v.SetProjection(fovDegrees, DefaultNear, DefaultFar)
+	v.depthBuffer = NewDepthBuffer(w, h)
+	return v
+}
+
+// SetProjection sets the field of view in degrees and the depth range of the camera
+func (v *viewState) SetProjection(fovDegrees, near, far float64) {
+	aspectRatio := v.W / v.H
+	fov := 1.0 / math.Tan(ToRadians(fovDegrees/2))
+	v.projection = Projection4x4(fov, aspectRatio, near, far)
+	v.near = near
+}
+
+// GetCameraPosition returns the current position of the camera
+func (v *viewState) GetCameraPosition() (x, y, z, yaw, pitch float64) {
+	return v.camera.X, v.camera.Y, v.camera.Z, v.yaw, v.pitch
+}
+
+// SetCameraPositionAbsolute sets the camera to the given absolute position
+func (v *viewState) SetCameraPositionAbsolute(x, y, z, yaw, pitch float64) {
+	v.camera.X = x
+	v.camera.Y = y
+	v.camera.Z = z
+	v.yaw = yaw
+	v.pitch = pitch
+}
+
+// SetCameraPositionRelative move the camera to a new position given the offsets
+func (v *viewState) SetCameraPositionRelative(dx, dy, dz, yaw, pitch float64) {
+	v.camera.X += dx
+	v.camera.Y += dy
+	v.camera.Z += dz
+	v.yaw += yaw
+	v.pitch += pitch
+}
+
+func (v *viewState) MoveCameraForward(amount float64) {
+	forward := v.direction.Mul(amount)
+	v.camera = v.camera.Add(&forward)
+}
+
+// updateCamera updates the global camera
+func (v *viewState) updateCamera() {
+	up := Vector3d{X: 0, Y: 1, Z: 0}
+	target := Vector3d{X: 0, Y: 0, Z: 1}
+
+	// Apply camera rotations
+	cameraYaw := Identity4x4()
+	cameraPitch := Identity4x4()
+
+	// TODO figure out why camera roll is not working
+	cameraYaw.RotateY(v.yaw)
+	cameraPitch.RotateX(v.pitch)
+
+	v.direction = cameraYaw.MulV(&target)
+	v.direction = cameraPitch.MulV(&v.direction)
+	target = v.camera.Add(&v.direction)
+
+	cameraMatrix := Identity4x4()
+	cameraMatrix.PointAt(&v.camera, &target, &up)
+	v.view = cameraMatrix.Inverse()
+}
+
+// Viewport renders its own camera into a rectangle of the target, e.g. for split
+// screen or the views of an editor. Its camera is moved like the one of the engine
+type Viewport struct {
+	viewState
+
+	// Samples per pixel in each direction, the state is scaled by it
+	scale int
+}
+
+// AddViewport adds a viewport for the given rectangle of the target, parts outside
+// of the target are cut off. Once an engine has viewports, `Render` renders all of
+// them in the order they were added instead of the camera of the engine. Meshes,
+// lights and settings are shared
+func (e *Engine) AddViewport(x, y, w, h int, fovDegrees float64) *Viewport {
+	targetW, targetH := e.targetSize()
+	x0, y0 := max(x, 0), max(y, 0)
+	x1, y1 := min(x+w, targetW), min(y+h, targetH)
+
+	// With anti-aliasing the viewport renders at the resolution of the samples
+	scale := e.sampleScale()
+	viewport := &Viewport{
+		viewState: newViewState(x0*scale, y0*scale, max(x1-x0, 0)*scale, max(y1-y0, 0)*scale, fovDegrees),
+		scale:     scale,
+	}
+	e.viewports = append(e.viewports, viewport)
+	return viewport
+}
+
+// Bounds returns the rectangle of the viewport in the target
+func (v *Viewport) Bounds() (x, y, w, h int) {
+	return v.offsetX / v.scale, v.offsetY / v.scale, v.w / v.scale, v.h / v.scale
+}
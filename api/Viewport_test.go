@@ -0,0 +1,65 @@
+package api
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestViewport(t *testing.T) {
+	sky := color.RGBA{B: 255, A: 255}
+	for _, opts := range []*EngineOptions{nil, {Rasterizer: RasterizerEdgeFunction}} {
+		drawn := map[[2]int]color.RGBA{}
+		engine := NewEngine(64, 32, 90, func(x, y int, c color.Color, userData UserData) {
+			drawn[[2]int{x, y}] = color.RGBAModel.Convert(c).(color.RGBA)
+		}, opts)
+
+		// The cube is close enough to cover the left viewport beyond its edges,
+		// the right one looks the other way
+		engine.AddMesh(ColoredCube())
+		engine.SetBackground(&SolidBackground{Color: sky})
+		left := engine.AddViewport(0, 0, 32, 32, 90)
+		left.SetCameraPositionAbsolute(0.5, 0.5, -0.3, 0, 0)
+		right := engine.AddViewport(32, 0, 40, 32, 90)
+		right.SetCameraPositionAbsolute(0.5, 0.5, -0.3, math.Pi, 0)
+		engine.Render(nil)
+
+		if x, y, w, h := right.Bounds(); x != 32 || y != 0 || w != 32 || h != 32 {
+			t.Fatalf("expected the right viewport to be cut off at the target, got %d/%d %dx%d", x, y, w, h)
+		}
+		if len(drawn) != 64*32 {
+			t.Fatalf("expected every pixel to be drawn, got %d", len(drawn))
+		}
+		for position, c := range drawn {
+			if position[0] >= 32 && c != sky {
+				t.Fatalf("pixel %v: expected the left view to stay in its viewport", position)
+			}
+		}
+		if drawn[[2]int{16, 16}] == sky {
+			t.Fatalf("expected the cube in the left viewport")
+		}
+	}
+}
+
+func TestViewport_SpanHook(t *testing.T) {
+	drawn := map[[2]int]bool{}
+	engine := NewEngine(64, 64, 90, nil, &EngineOptions{
+		Rasterizer: RasterizerEdgeFunction,
+		SpanHook: func(y, x0, x1 int, colors []color.RGBA, userData UserData) {
+			if y < 32 || x0 < 32 || x1 > 64 {
+				t.Fatalf("span %d..%d in row %d outside of the viewport", x0, x1, y)
+			}
+			for x := x0; x < x1; x++ {
+				drawn[[2]int{x, y}] = true
+			}
+		},
+	})
+	engine.AddMesh(ColoredCube())
+	viewport := engine.AddViewport(32, 32, 32, 32, 90)
+	viewport.SetCameraPositionAbsolute(0.5, 0.5, -0.3, 0, 0)
+	engine.Render(nil)
+
+	if len(drawn) != 32*32 {
+		t.Fatalf("expected the viewport to be covered, got %d pixels", len(drawn))
+	}
+}
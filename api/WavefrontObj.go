@@ -0,0 +1,192 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+// objMaterial is a material of the companion MTL file, one per distinct
+// triangle color
+type objMaterial struct {
+	name  string
+	color color.Color
+}
+
+// objVertex is a vertex position with its optional color, vertices are
+// deduplicated by both
+type objVertex struct {
+	x, y, z float64
+	colored bool
+	r, g, b float64
+}
+
+// newObjVertex returns the key of a triangle vertex. Vertex colors are written
+// without alpha, as the `v x y z r g b` extension has no alpha channel
+func newObjVertex(triangle *Triangle, index int) objVertex {
+	v := triangle.Vertices[index]
+	result := objVertex{x: v.X, y: v.Y, z: v.Z}
+	if c := triangle.VertexColors[index]; c != nil {
+		rgba := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+		result.colored = true
+		result.r = float64(rgba.R) / 0xFFFF
+		result.g = float64(rgba.G) / 0xFFFF
+		result.b = float64(rgba.B) / 0xFFFF
+	}
+	return result
+}
+
+// objFloat formats a float so that it is parsed back without loss
+func objFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// WriteWavefrontObj writes the mesh in Wavefront obj format. Vertices, texture
+// coordinates and normals are deduplicated, vertex colors are appended to the
+// vertex position. Texture coordinates are written unchanged, so the file loads
+// back through `LoadWavefrontObj` with the same `YOrigin` convention
+func (m *Mesh) WriteWavefrontObj(w io.Writer) error {
+	return m.WriteWavefrontObjWithMaterials(w, nil, "")
+}
+
+// WriteWavefrontObjWithMaterials writes the mesh in Wavefront obj format like
+// `WriteWavefrontObj` and a companion MTL file to `mtl`. Every distinct triangle
+// color becomes a material with a diffuse color, textured triangles share a single
+// material. `mtlName` is the file name the obj file uses to reference the MTL file
+func (m *Mesh) WriteWavefrontObjWithMaterials(w, mtl io.Writer, mtlName string) error {
+	writer := bufio.NewWriter(w)
+
+	vertexIndices := map[objVertex]int{}
+	uvIndices := map[[2]float64]int{}
+	normalIndices := map[[3]float64]int{}
+
+	var materials []objMaterial
+	materialIndices := map[color.Color]int{}
+
+	if mtl != nil {
+		if _, err := fmt.Fprintf(writer, "mtllib %s\n", mtlName); err != nil {
+			return err
+		}
+	}
+
+	// Write all unique vertices, texture coordinates and normals first, faces
+	// reference them by index
+	for _, triangle := range m.triangles {
+		for i := range triangle.Vertices {
+			key := newObjVertex(&triangle, i)
+			if _, ok := vertexIndices[key]; !ok {
+				vertexIndices[key] = len(vertexIndices) + 1
+				line := fmt.Sprintf("v %s %s %s", objFloat(key.x), objFloat(key.y), objFloat(key.z))
+				if key.colored {
+					line += fmt.Sprintf(" %s %s %s", objFloat(key.r), objFloat(key.g), objFloat(key.b))
+				}
+				if _, err := writer.WriteString(line + "\n"); err != nil {
+					return err
+				}
+			}
+		}
+
+		if triangle.Color == nil {
+			for i := range triangle.UVs {
+				uv := triangle.UVs[i]
+				key := [2]float64{uv.U, uv.V}
+				if _, ok := uvIndices[key]; !ok {
+					uvIndices[key] = len(uvIndices) + 1
+					if _, err := fmt.Fprintf(writer, "vt %s %s\n", objFloat(uv.U), objFloat(uv.V)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if triangle.hasNormals() {
+			for i := range triangle.Normals {
+				n := triangle.Normals[i]
+				key := [3]float64{n.X, n.Y, n.Z}
+				if _, ok := normalIndices[key]; !ok {
+					normalIndices[key] = len(normalIndices) + 1
+					if _, err := fmt.Fprintf(writer, "vn %s %s %s\n", objFloat(n.X), objFloat(n.Y), objFloat(n.Z)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	currentMaterial := -1
+	for _, triangle := range m.triangles {
+		if mtl != nil {
+			material, ok := materialIndices[triangle.Color]
+			if !ok {
+				material = len(materials)
+				materialIndices[triangle.Color] = material
+				name := fmt.Sprintf("color_%d", material)
+				if triangle.Color == nil {
+					name = "textured"
+				}
+				materials = append(materials, objMaterial{name: name, color: triangle.Color})
+			}
+			if material != currentMaterial {
+				currentMaterial = material
+				if _, err := fmt.Fprintf(writer, "usemtl %s\n", materials[material].name); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := writer.WriteString("f"); err != nil {
+			return err
+		}
+		for i := range triangle.Vertices {
+			vertex := strconv.Itoa(vertexIndices[newObjVertex(&triangle, i)])
+
+			uv := ""
+			if triangle.Color == nil {
+				uv = strconv.Itoa(uvIndices[[2]float64{triangle.UVs[i].U, triangle.UVs[i].V}])
+			}
+
+			var err error
+			if triangle.hasNormals() {
+				n := triangle.Normals[i]
+				_, err = fmt.Fprintf(writer, " %s/%s/%d", vertex, uv, normalIndices[[3]float64{n.X, n.Y, n.Z}])
+			} else if uv != "" {
+				_, err = fmt.Fprintf(writer, " %s/%s", vertex, uv)
+			} else {
+				_, err = fmt.Fprintf(writer, " %s", vertex)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := writer.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if mtl != nil {
+		return writeMtl(mtl, materials)
+	}
+	return nil
+}
+
+// writeMtl writes the materials of an obj file
+func writeMtl(w io.Writer, materials []objMaterial) error {
+	writer := bufio.NewWriter(w)
+	for _, material := range materials {
+		r, g, b, a := 1.0, 1.0, 1.0, 1.0
+		if material.color != nil {
+			c := color.NRGBAModel.Convert(material.color).(color.NRGBA)
+			r, g, b, a = float64(c.R)/255, float64(c.G)/255, float64(c.B)/255, float64(c.A)/255
+		}
+		if _, err := fmt.Fprintf(writer, "newmtl %s\nKd %s %s %s\nd %s\n\n", material.name, objFloat(r), objFloat(g), objFloat(b), objFloat(a)); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
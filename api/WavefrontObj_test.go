@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMesh_WriteWavefrontObj(t *testing.T) {
+	mesh := StandardCube()
+	for i := range mesh.triangles {
+		mesh.triangles[i].Normals = [3]Vector3d{{Y: 1}, {Y: 1}, {Y: 1}}
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := mesh.WriteWavefrontObj(buffer); err != nil {
+		t.Fatalf("WriteWavefrontObj: %v", err)
+	}
+
+	// A cube has 8 unique vertices
+	if count := strings.Count(buffer.String(), "\nv "); count != 7 {
+		t.Fatalf("expected 8 deduplicated vertices, got %d", count+1)
+	}
+
+	filename := filepath.Join(t.TempDir(), "cube.obj")
+	if err := os.WriteFile(filename, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadWavefrontObj(filename)
+	if err != nil {
+		t.Fatalf("LoadWavefrontObj: %v", err)
+	}
+	if len(loaded.triangles) != len(mesh.triangles) {
+		t.Fatalf("expected %d triangles, got %d", len(mesh.triangles), len(loaded.triangles))
+	}
+
+	for i, triangle := range loaded.triangles {
+		expected := mesh.triangles[i]
+		for v := range triangle.Vertices {
+			if triangle.Vertices[v] != expected.Vertices[v] {
+				t.Fatalf("triangle %d vertex %d: expected %v, got %v", i, v, expected.Vertices[v], triangle.Vertices[v])
+			}
+			if triangle.UVs[v].U != expected.UVs[v].U || triangle.UVs[v].V != expected.UVs[v].V {
+				t.Fatalf("triangle %d uv %d: expected %v, got %v", i, v, expected.UVs[v], triangle.UVs[v])
+			}
+			if triangle.Normals[v] != expected.Normals[v] {
+				t.Fatalf("triangle %d normal %d: expected %v, got %v", i, v, expected.Normals[v], triangle.Normals[v])
+			}
+		}
+	}
+}
+
+func TestLoadWavefrontObj_VertexColors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "colored.obj")
+	obj := "v 0 0 0 1 0 0\nv 1 0 0 0 1 0\nv 0 1 0 0 0 1\nf 1 2 3\n"
+	if err := os.WriteFile(filename, []byte(obj), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mesh, err := LoadWavefrontObj(filename)
+	if err != nil {
+		t.Fatalf("LoadWavefrontObj: %v", err)
+	}
+
+	expected := [3][3]uint32{{0xFFFF, 0, 0}, {0, 0xFFFF, 0}, {0, 0, 0xFFFF}}
+	for i, c := range mesh.triangles[0].VertexColors {
+		if c == nil {
+			t.Fatalf("vertex %d has no color", i)
+		}
+		r, g, b, _ := c.RGBA()
+		if [3]uint32{r, g, b} != expected[i] {
+			t.Fatalf("vertex %d: expected %v, got %v", i, expected[i], [3]uint32{r, g, b})
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := mesh.WriteWavefrontObj(buffer); err != nil {
+		t.Fatalf("WriteWavefrontObj: %v", err)
+	}
+	if !strings.Contains(buffer.String(), "v 1 0 0 0 1 0\n") {
+		t.Fatalf("vertex colors not written:\n%s", buffer.String())
+	}
+}
+
+// writeHighPolyObj writes a sphere with about 32k triangles to an obj file in a
+// temporary directory
+func writeHighPolyObj(b *testing.B) string {
+	filename := filepath.Join(b.TempDir(), "sphere.obj")
+	file, err := os.Create(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := UVSphere(1, 128, 128).WriteWavefrontObj(file); err != nil {
+		b.Fatal(err)
+	}
+	return filename
+}
+
+func BenchmarkLoadWavefrontObj(b *testing.B) {
+	filename := writeHighPolyObj(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadWavefrontObj(filename); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
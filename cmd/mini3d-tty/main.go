@@ -0,0 +1,262 @@
+// Command mini3d-tty previews a Wavefront OBJ model in the terminal. The model
+// spins in front of the camera, which orbits it with the keyboard:
+//
+//	left/right, a/d  spin the model
+//	up/down, w/s     raise and lower the camera
+//	+/-              zoom in and out
+//	space            toggle the automatic rotation
+//	q, ctrl-c        quit
+//
+// Without a file a cube is shown.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"time"
+
+	"github.com/pb82/mini3d/api"
+	"github.com/pb82/mini3d/terminal"
+)
+
+// Direction towards the light in world space
+var lightDirection = normalized(api.Vector3d{X: -0.4, Y: 0.6, Z: -0.7})
+
+// Color of triangles without a color of their own
+var modelColor = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+
+func normalized(v api.Vector3d) api.Vector3d {
+	v.Normalize()
+	return v
+}
+
+// viewer holds the model and the orbit of the camera around it
+type viewer struct {
+	mesh     *api.Mesh
+	radius   float64
+	spin     float64
+	pitch    float64
+	distance float64
+	rotate   bool
+
+	mode   terminal.Mode
+	engine *api.Engine
+	screen *terminal.Screen
+}
+
+// resize recreates the engine and the screen if the size of the terminal changed.
+// The last row of the terminal is kept for the status line
+func (v *viewer) resize(columns, rows int) {
+	w, h := max(columns, 1), max(rows-1, 1)*2
+	if v.screen != nil && v.screen.W() == w && v.screen.H() == h {
+		return
+	}
+
+	v.screen = terminal.NewScreen(w, h, v.mode)
+	v.engine = api.NewEngine(w, h, 60, nil, &api.EngineOptions{
+		Rasterizer: api.RasterizerEdgeFunction,
+		SpanHook:   v.screen.DrawSpan,
+	})
+	v.engine.SetBackground(&api.GradientBackground{
+		Top:    color.RGBA{R: 40, G: 60, B: 90, A: 255},
+		Bottom: color.RGBA{R: 10, G: 10, B: 15, A: 255},
+	})
+	v.engine.AddMesh(v.mesh)
+}
+
+// key applies a key press, it returns false if the viewer should quit
+func (v *viewer) key(key string) bool {
+	const step = 0.1
+	switch key {
+	case "q", "\x03":
+		return false
+	case "\x1b[D", "a":
+		v.spin -= step
+	case "\x1b[C", "d":
+		v.spin += step
+	case "\x1b[A", "w":
+		v.pitch = min(v.pitch+step, 1.5)
+	case "\x1b[B", "s":
+		v.pitch = max(v.pitch-step, -1.5)
+	case "+", "=":
+		v.distance = max(v.distance*0.9, v.radius*1.1)
+	case "-", "_":
+		v.distance *= 1.1
+	case " ":
+		v.rotate = !v.rotate
+	}
+	return true
+}
+
+// render draws a frame into the terminal
+func (v *viewer) render(out *bufio.Writer) error {
+	// The model spins around its center at the origin, the camera looks at it
+	// from above or below
+	v.mesh.RotateYAroundOrigin(v.spin)
+	v.engine.SetCameraPositionAbsolute(
+		0,
+		v.distance*math.Sin(v.pitch),
+		-v.distance*math.Cos(v.pitch),
+		0,
+		v.pitch,
+	)
+
+	v.screen.Clear()
+	v.engine.Render(nil)
+	if _, err := v.screen.WriteTo(out); err != nil {
+		return err
+	}
+
+	// Dumb terminals scroll, the status line ends the frame
+	m := &v.engine.Metrics
+	status := fmt.Sprintf("%d triangles, %v per frame", m.Triangles, m.FrameTime.Round(time.Microsecond))
+	if v.mode == terminal.ModeASCII {
+		fmt.Fprintf(out, "\n%s\n", status)
+	} else {
+		fmt.Fprintf(out, "\n\x1b[0m\x1b[K%s", status)
+	}
+	return out.Flush()
+}
+
+// shading lights the model with a single directional light. Models without
+// normals use the direction from their center instead
+func shading() *api.Material {
+	return &api.Material{
+		VertexShader: func(in *api.VertexInput, out *api.Varyings) api.Vector3d {
+			normal := in.Normal
+			if normal.X == 0 && normal.Y == 0 && normal.Z == 0 {
+				normal = in.Position
+			}
+			normal.W = 0
+			normal = in.Uniforms.World.MulV(&normal)
+			normal.Normalize()
+			out[0] = 0.25 + 0.75*max(0, normal.Dot(&lightDirection))
+			return api.DefaultVertexShader(in, out)
+		},
+		FragmentShader: func(in *api.FragmentInput) (color.Color, bool) {
+			c := modelColor
+			if in.Color != nil {
+				c = color.RGBAModel.Convert(in.Color).(color.RGBA)
+			}
+			light := min(1, in.Varyings[0])
+			return color.RGBA{
+				R: uint8(float64(c.R) * light),
+				G: uint8(float64(c.G) * light),
+				B: uint8(float64(c.B) * light),
+				A: c.A,
+			}, true
+		},
+	}
+}
+
+// loadModel loads the model and moves its center to the origin
+func loadModel(path string) (*api.Mesh, float64, error) {
+	mesh := api.StandardCube()
+	if path != "" {
+		var err error
+		if mesh, err = api.LoadWavefrontObj(path); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	center := mesh.GetCenter()
+	mesh.MoveRelative(center.X, center.Y, center.Z)
+	size := mesh.GetBoundingBox()
+	radius := math.Sqrt(size.X*size.X+size.Y*size.Y+size.Z*size.Z) / 2
+	mesh.SetMaterial(shading())
+	return mesh, max(radius, 0.01), nil
+}
+
+func main() {
+	ascii := flag.Bool("ascii", false, "draw characters instead of colors, the default for dumb terminals")
+	fps := flag.Int("fps", 30, "frames per second")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [model.obj]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	mesh, radius, err := loadModel(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	v := &viewer{
+		mesh:     mesh,
+		radius:   radius,
+		pitch:    0.3,
+		distance: radius * 2.5,
+		rotate:   true,
+		mode:     terminal.DetectMode(),
+	}
+	if *ascii {
+		v.mode = terminal.ModeASCII
+	}
+
+	stdin := int(os.Stdin.Fd())
+	state, err := terminal.MakeRaw(stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "stdin is not a terminal:", err)
+		os.Exit(1)
+	}
+
+	// Switch to the alternate screen without cursor and back when done. Dumb
+	// terminals do not understand control sequences
+	out := bufio.NewWriter(os.Stdout)
+	ansi := v.mode != terminal.ModeASCII
+	if ansi {
+		fmt.Fprint(out, "\x1b[?1049h\x1b[?25l\x1b[2J")
+	}
+	defer func() {
+		if ansi {
+			fmt.Fprint(out, "\x1b[0m\x1b[?25h\x1b[?1049l")
+		}
+		out.Flush()
+		terminal.Restore(stdin, state)
+	}()
+
+	// Keys arrive as single bytes or escape sequences in one read
+	keys := make(chan string)
+	go func() {
+		buffer := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buffer)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- string(buffer[:n])
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second / time.Duration(max(*fps, 1)))
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || !v.key(key) {
+				return
+			}
+		case now := <-ticker.C:
+			if v.rotate {
+				v.spin += now.Sub(last).Seconds() * 0.8
+			}
+			last = now
+
+			columns, rows, err := terminal.Size(int(os.Stdout.Fd()))
+			if err != nil {
+				columns, rows = 80, 24
+			}
+			v.resize(columns, rows)
+			if err := v.render(out); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+#!/bin/sh
+# Runs the benchmark suite of the api package and compares it between two git
+# revisions with benchstat.
+#
+# Usage:
+#   scripts/bench.sh                  benchmark the working tree
+#   scripts/bench.sh <old> [<new>]    compare revision <old> with <new> (default:
+#                                     the working tree)
+#
+# BENCH selects benchmarks (default: .), COUNT sets the number of runs per
+# benchmark (default: 10). Results are written to bench/<name>.txt.
+set -e
+
+BENCH=${BENCH:-.}
+COUNT=${COUNT:-10}
+ROOT=$(git rev-parse --show-toplevel)
+OUT="$ROOT/bench"
+mkdir -p "$OUT"
+
+# run <directory> <name> benchmarks the api package in <directory>
+run() {
+	echo "benchmarking $2" >&2
+	(cd "$1" && go test ./api -run '^$' -bench "$BENCH" -benchmem -count "$COUNT") > "$OUT/$2.txt"
+}
+
+# checkout <revision> benchmarks <revision> in a temporary worktree
+checkout() {
+	dir=$(mktemp -d)
+	git -C "$ROOT" worktree add --detach "$dir" "$1" >/dev/null 2>&1
+	run "$dir" "$(echo "$1" | tr '/' '_')"
+	git -C "$ROOT" worktree remove --force "$dir"
+}
+
+if [ $# -eq 0 ]; then
+	run "$ROOT" current
+	exec cat "$OUT/current.txt"
+fi
+
+checkout "$1"
+old="$OUT/$(echo "$1" | tr '/' '_').txt"
+if [ $# -ge 2 ]; then
+	checkout "$2"
+	new="$OUT/$(echo "$2" | tr '/' '_').txt"
+else
+	run "$ROOT" current
+	new="$OUT/current.txt"
+fi
+
+if command -v benchstat >/dev/null 2>&1; then
+	benchstat "$old" "$new"
+else
+	go run golang.org/x/perf/cmd/benchstat@latest "$old" "$new"
+fi
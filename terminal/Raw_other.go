@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !freebsd
+
+package terminal
+
+import "fmt"
+
+var errUnsupported = fmt.Errorf("raw terminal mode is not supported on this platform")
+
+// State is the configuration of a terminal before it was switched to raw mode
+type State struct{}
+
+// MakeRaw is not supported on this platform
+func MakeRaw(fd int) (*State, error) {
+	return nil, errUnsupported
+}
+
+// Restore is not supported on this platform
+func Restore(fd int, state *State) error {
+	return errUnsupported
+}
+
+// Size is not supported on this platform
+func Size(fd int) (columns, rows int, err error) {
+	return 0, 0, errUnsupported
+}
@@ -0,0 +1,60 @@
+//go:build linux || darwin || freebsd
+
+package terminal
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// State is the configuration of a terminal before it was switched to raw mode
+type State struct {
+	termios syscall.Termios
+}
+
+// MakeRaw switches the terminal on fd to raw mode: input is read byte by byte
+// without echo, line editing or signals. Output processing is kept, so newlines
+// still return the cursor. The returned state restores the terminal
+func MakeRaw(fd int) (*State, error) {
+	var termios syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&termios)); err != nil {
+		return nil, err
+	}
+	state := &State{termios: termios}
+
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, ioctlSetTermios, unsafe.Pointer(&termios)); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Restore puts the terminal on fd back into the state it had before `MakeRaw`
+func Restore(fd int, state *State) error {
+	return ioctl(fd, ioctlSetTermios, unsafe.Pointer(&state.termios))
+}
+
+// Size returns the number of columns and rows of the terminal on fd
+func Size(fd int) (columns, rows int, err error) {
+	var size struct {
+		rows, columns, width, height uint16
+	}
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil {
+		return 0, 0, err
+	}
+	return int(size.columns), int(size.rows), nil
+}
+
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Package terminal renders the output of an engine to a text terminal
+package terminal
+
+import (
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pb82/mini3d/api"
+)
+
+// Mode selects how pixels are written to the terminal
+type Mode int
+
+const (
+	// ModeTrueColor draws two pixels per character cell, the upper one as the
+	// foreground of an upper half block and the lower one as its background, in
+	// 24-bit ANSI colors
+	ModeTrueColor Mode = iota
+
+	// ModeASCII draws two pixels per character cell as a character of a
+	// brightness ramp, for dumb terminals. No control sequences are written, so
+	// frames follow each other instead of replacing the previous one
+	ModeASCII
+)
+
+// asciiRamp orders characters from dark to bright
+const asciiRamp = " .:-=+*#%@"
+
+// DetectMode returns `ModeASCII` for dumb terminals and `ModeTrueColor` for all
+// others
+func DetectMode() Mode {
+	if term := os.Getenv("TERM"); term == "" || term == "dumb" {
+		return ModeASCII
+	}
+	return ModeTrueColor
+}
+
+// Screen collects the output of an engine and writes it to a terminal. Its
+// `DrawPixel` and `DrawSpan` methods are used as the hooks of the engine
+type Screen struct {
+	// Background is blended under pixels that are transparent or only partly
+	// covered, black by default
+	Background color.RGBA
+
+	w, h   int
+	mode   Mode
+	pixels []color.RGBA
+
+	// Output of the last frame, reused
+	out []byte
+}
+
+// NewScreen creates a screen of the given size in pixels. Every character cell
+// shows two pixels on top of each other, an odd height is rounded up
+func NewScreen(w, h int, mode Mode) *Screen {
+	h += h % 2
+	return &Screen{
+		Background: color.RGBA{A: 255},
+		w:          w,
+		h:          h,
+		mode:       mode,
+		pixels:     make([]color.RGBA, w*h),
+	}
+}
+
+func (s *Screen) W() int {
+	return s.w
+}
+
+func (s *Screen) H() int {
+	return s.h
+}
+
+// Clear makes all pixels transparent
+func (s *Screen) Clear() {
+	clear(s.pixels)
+}
+
+// DrawPixel sets a pixel, it is an `api.DrawHook`
+func (s *Screen) DrawPixel(x, y int, c color.Color, userData api.UserData) {
+	if x < 0 || y < 0 || x >= s.w || y >= s.h {
+		return
+	}
+	r, g, b, a := c.RGBA()
+	s.pixels[y*s.w+x] = color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}
+}
+
+// DrawSpan sets a run of pixels, it is an `api.SpanHook`. Pixels outside of the
+// screen are skipped
+func (s *Screen) DrawSpan(y, x0, x1 int, colors []color.RGBA, userData api.UserData) {
+	if y < 0 || y >= s.h {
+		return
+	}
+	start, end := max(x0, 0), min(x1, s.w, x0+len(colors))
+	if start >= end {
+		return
+	}
+	copy(s.pixels[y*s.w+start:y*s.w+end], colors[start-x0:])
+}
+
+// pixel returns a pixel blended over the background
+func (s *Screen) pixel(x, y int) color.RGBA {
+	c := s.pixels[y*s.w+x]
+	if c.A == 255 {
+		return c
+	}
+	blend := func(v, background uint8) uint8 {
+		return v + uint8(uint32(background)*uint32(255-c.A)/255)
+	}
+	return color.RGBA{
+		R: blend(c.R, s.Background.R),
+		G: blend(c.G, s.Background.G),
+		B: blend(c.B, s.Background.B),
+		A: 255,
+	}
+}
+
+// WriteTo writes the screen to a terminal, starting in its upper left corner or
+// at the cursor in `ModeASCII`. Rows are separated by newlines, the cursor stays
+// at the end of the last row
+func (s *Screen) WriteTo(w io.Writer) (int64, error) {
+	out := s.out[:0]
+	if s.mode == ModeTrueColor {
+		out = append(out, "\x1b[H"...)
+	}
+
+	for row := 0; row < s.h/2; row++ {
+		if row > 0 {
+			out = append(out, '\n')
+		}
+
+		var foreground, background color.RGBA
+		colored := false
+		for x := 0; x < s.w; x++ {
+			top, bottom := s.pixel(x, 2*row), s.pixel(x, 2*row+1)
+
+			if s.mode == ModeASCII {
+				luma := (luminance(top) + luminance(bottom)) / 2
+				out = append(out, asciiRamp[min(len(asciiRamp)-1, int(luma*float64(len(asciiRamp))))])
+				continue
+			}
+
+			// Colors are only set when they change
+			if !colored || top != foreground {
+				out = appendColor(out, "\x1b[38;2;", top)
+			}
+			if !colored || bottom != background {
+				out = appendColor(out, "\x1b[48;2;", bottom)
+			}
+			foreground, background, colored = top, bottom, true
+			out = append(out, "▀"...)
+		}
+		if s.mode == ModeTrueColor {
+			out = append(out, "\x1b[0m"...)
+		}
+	}
+
+	s.out = out
+	n, err := w.Write(out)
+	return int64(n), err
+}
+
+// appendColor appends an SGR sequence setting a 24-bit color
+func appendColor(out []byte, prefix string, c color.RGBA) []byte {
+	out = append(out, prefix...)
+	out = strconv.AppendInt(out, int64(c.R), 10)
+	out = append(out, ';')
+	out = strconv.AppendInt(out, int64(c.G), 10)
+	out = append(out, ';')
+	out = strconv.AppendInt(out, int64(c.B), 10)
+	return append(out, 'm')
+}
+
+// luminance returns the perceived brightness of a color from 0 to 1
+func luminance(c color.RGBA) float64 {
+	return (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+}
@@ -0,0 +1,75 @@
+package terminal
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+func TestScreen_TrueColor(t *testing.T) {
+	screen := NewScreen(2, 1, ModeTrueColor)
+	if screen.H() != 2 {
+		t.Fatalf("expected the height to be rounded up to 2, got %d", screen.H())
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	screen.DrawSpan(0, 0, 2, []color.RGBA{red, red}, nil)
+	screen.DrawPixel(1, 1, color.RGBA{B: 128, A: 128}, nil)
+
+	var out bytes.Buffer
+	if _, err := screen.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second cell only changes the background, the transparent blue is
+	// blended over black
+	expected := "\x1b[H" +
+		"\x1b[38;2;255;0;0m\x1b[48;2;0;0;0m▀" +
+		"\x1b[48;2;0;0;128m▀" +
+		"\x1b[0m"
+	if out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestScreen_ASCII(t *testing.T) {
+	screen := NewScreen(3, 4, ModeASCII)
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	screen.DrawSpan(0, 0, 3, []color.RGBA{white, white, {}}, nil)
+	screen.DrawSpan(1, 0, 3, []color.RGBA{white, {}, {}}, nil)
+
+	var out bytes.Buffer
+	if _, err := screen.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "@+ \n   "; out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+
+	screen.Clear()
+	out.Reset()
+	screen.WriteTo(&out)
+	if expected := "   \n   "; out.String() != expected {
+		t.Fatalf("expected a cleared screen, got %q", out.String())
+	}
+}
+
+func TestScreen_DrawSpan(t *testing.T) {
+	screen := NewScreen(3, 2, ModeASCII)
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	row := []color.RGBA{white, white, white, white, white}
+
+	// Spans reaching over the edges are clipped, rows outside are skipped
+	screen.DrawSpan(0, -2, 3, row, nil)
+	screen.DrawSpan(1, 2, 7, row, nil)
+	screen.DrawSpan(-1, 0, 3, row, nil)
+	screen.DrawSpan(2, 0, 3, row, nil)
+
+	var out bytes.Buffer
+	if _, err := screen.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "++@"; out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}